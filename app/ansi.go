@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// styledText is output split into its visible characters and the SGR styling active for each
+type styledText struct {
+	text   []rune
+	styles []string
+}
+
+// parseANSI separates visible text from escape sequences, tracking SGR styling per character
+func parseANSI(s string) styledText {
+	var st styledText
+	style := ""
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '\x1b' || i+1 >= len(runes) {
+			st.text = append(st.text, runes[i])
+			st.styles = append(st.styles, style)
+			continue
+		}
+		switch runes[i+1] {
+		case '[':
+			// CSI: parameters up to a final byte in @..~
+			j := i + 2
+			for j < len(runes) && (runes[j] < '@' || runes[j] > '~') {
+				j++
+			}
+			if j < len(runes) && runes[j] == 'm' {
+				params := string(runes[i+2 : j])
+				if params == "" || params == "0" {
+					style = ""
+				} else if style == "" {
+					style = params
+				} else {
+					style += ";" + params
+				}
+			}
+			i = j
+		case ']':
+			// OSC: terminated by BEL or ESC \
+			j := i + 2
+			for j < len(runes) && runes[j] != '\a' && !(runes[j] == '\x1b' && j+1 < len(runes) && runes[j+1] == '\\') {
+				j++
+			}
+			if j < len(runes) && runes[j] == '\x1b' {
+				j++
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+	return st
+}
+
+// stripANSI returns only the visible text of s
+func stripANSI(s string) string {
+	return string(parseANSI(s).text)
+}
+
+// compareANSI classifies how two outputs differ once escape sequences are interpreted
+func compareANSI(bash, mini string) string {
+	b, m := parseANSI(bash), parseANSI(mini)
+	if string(b.text) != string(m.text) {
+		return "different text"
+	}
+	for i := range b.styles {
+		if b.styles[i] != m.styles[i] {
+			return fmt.Sprintf("same text, different styling at character %d %q (bash: %q, minishell: %q)",
+				i+1, string(b.text[i]), b.styles[i], m.styles[i])
+		}
+	}
+	if bash != mini {
+		return "same text and styling, different escape sequences"
+	}
+	return "identical"
+}
+
+// hasANSI reports whether s contains an escape character
+func hasANSI(s string) bool {
+	return strings.ContainsRune(s, '\x1b')
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Assertion is one check on minishell's behavior in a test's assertions list
+type Assertion struct {
+	// Type is one of the keys of assertionChecks, such as "stdout_contains"
+	Type string `json:"type"`
+	// Value is the text or regex checked against, for the types that take one
+	Value string `json:"value,omitempty"`
+	// Code is the exit code checked by "exit_code"
+	Code int `json:"code,omitempty"`
+}
+
+// assertionRun is what an assertion is checked against
+type assertionRun struct {
+	stdout, stderr string
+	exitCode       int
+}
+
+// assertionChecks maps each assertion type to the check it performs
+var assertionChecks = map[string]func(a Assertion, run assertionRun) bool{
+	"stdout_equals":   func(a Assertion, run assertionRun) bool { return run.stdout == a.Value },
+	"stdout_contains": func(a Assertion, run assertionRun) bool { return strings.Contains(run.stdout, a.Value) },
+	"stdout_matches":  func(a Assertion, run assertionRun) bool { return regexp.MustCompile(a.Value).MatchString(run.stdout) },
+	"stdout_empty":    func(_ Assertion, run assertionRun) bool { return run.stdout == "" },
+	"stderr_equals":   func(a Assertion, run assertionRun) bool { return run.stderr == a.Value },
+	"stderr_contains": func(a Assertion, run assertionRun) bool { return strings.Contains(run.stderr, a.Value) },
+	"stderr_matches":  func(a Assertion, run assertionRun) bool { return regexp.MustCompile(a.Value).MatchString(run.stderr) },
+	"stderr_empty":    func(_ Assertion, run assertionRun) bool { return run.stderr == "" },
+	"exit_code":       func(a Assertion, run assertionRun) bool { return run.exitCode == a.Code },
+}
+
+// validateAssertions reports an error for an unknown assertion type or an invalid regex
+func validateAssertions(assertions []Assertion) error {
+	for _, a := range assertions {
+		if _, ok := assertionChecks[a.Type]; !ok {
+			return fmt.Errorf("unknown assertion type %q", a.Type)
+		}
+		if strings.HasSuffix(a.Type, "_matches") {
+			if _, err := regexp.Compile(a.Value); err != nil {
+				return fmt.Errorf("invalid %s pattern: %v", a.Type, err)
+			}
+		}
+	}
+	return nil
+}
+
+// String describes the assertion as it appears in failure reports
+func (a Assertion) String() string {
+	switch {
+	case a.Type == "exit_code":
+		return fmt.Sprintf("exit_code %d", a.Code)
+	case strings.HasSuffix(a.Type, "_empty"):
+		return a.Type
+	default:
+		return fmt.Sprintf("%s %q", a.Type, a.Value)
+	}
+}
+
+// failedAssertions checks every assertion against a run and describes the ones that don't hold
+func failedAssertions(assertions []Assertion, run assertionRun) []string {
+	var failed []string
+	for _, a := range assertions {
+		if !assertionChecks[a.Type](a, run) {
+			failed = append(failed, a.String())
+		}
+	}
+	return failed
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// benchStats summarizes the durations of repeated runs of one command in one shell
+type benchStats struct {
+	Min, Median, Max, Mean time.Duration
+	TimedOut               int
+}
+
+// summarizeDurations computes min/median/max/mean of a non-empty set of durations
+func summarizeDurations(durations []time.Duration) benchStats {
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	median := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+	return benchStats{
+		Min:    sorted[0],
+		Median: median,
+		Max:    sorted[len(sorted)-1],
+		Mean:   total / time.Duration(len(sorted)),
+	}
+}
+
+// bench runs a command the given number of times in a shell and summarizes how long it took
+func (st *ShellTester) bench(shellPath string, tc TestCase, runs int) benchStats {
+	durations := make([]time.Duration, 0, runs)
+	timedOut := 0
+	for i := 0; i < runs; i++ {
+		res := st.runCommand(shellPath, tc)
+		if res.timedOut {
+			timedOut++
+		}
+		durations = append(durations, res.duration)
+	}
+	stats := summarizeDurations(durations)
+	stats.TimedOut = timedOut
+	return stats
+}
+
+// writeBenchCSV writes the bench results as CSV with durations in microseconds
+func writeBenchCSV(path string, rows map[string]benchStats) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating CSV file: %v", err)
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	_ = w.Write([]string{"shell", "min_us", "median_us", "max_us", "mean_us", "timed_out"})
+	for _, shell := range []string{"bash", "minishell"} {
+		s := rows[shell]
+		_ = w.Write([]string{
+			shell,
+			strconv.FormatInt(s.Min.Microseconds(), 10),
+			strconv.FormatInt(s.Median.Microseconds(), 10),
+			strconv.FormatInt(s.Max.Microseconds(), 10),
+			strconv.FormatInt(s.Mean.Microseconds(), 10),
+			strconv.Itoa(s.TimedOut),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("error writing CSV file: %v", err)
+	}
+	return nil
+}
+
+// runBench implements the bench subcommand
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	bashPath := fs.String("bash", "/bin/bash", "Path to Bash executable")
+	minishellPath := fs.String("minishell", "./minishell", "Path to Minishell executable")
+	command := fs.String("command", "", "Command to benchmark in both shells")
+	runs := fs.Int("runs", 10, "Number of times to run the command in each shell")
+	timeout := fs.Duration("timeout", defaultTimeout, "Per-run timeout")
+	csvPath := fs.String("csv", "", "Path to also write the results as CSV")
+	_ = fs.Parse(args)
+
+	if *command == "" {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: -command is required\n")
+		os.Exit(1)
+	}
+	if *runs < 1 {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: -runs must be at least 1\n")
+		os.Exit(1)
+	}
+
+	tester, err := NewShellTester(*bashPath, *minishellPath)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	tester.timeout = *timeout
+
+	tc := TestCase{Command: *command, Description: *command}
+	rows := map[string]benchStats{
+		"bash":      tester.bench(tester.bashPath, tc, *runs),
+		"minishell": tester.bench(tester.minishellPath, tc, *runs),
+	}
+
+	fmt.Printf("Benchmark: %s (%d runs)\n", *command, *runs)
+	fmt.Printf("%-10s %12s %12s %12s %12s\n", "shell", "min", "median", "max", "mean")
+	for _, shell := range []string{"bash", "minishell"} {
+		s := rows[shell]
+		fmt.Printf("%-10s %12s %12s %12s %12s", shell,
+			s.Min.Round(time.Microsecond), s.Median.Round(time.Microsecond),
+			s.Max.Round(time.Microsecond), s.Mean.Round(time.Microsecond))
+		if s.TimedOut > 0 {
+			fmt.Printf("  (%d timed out)", s.TimedOut)
+		}
+		fmt.Println()
+	}
+	if bash := rows["bash"].Median; bash > 0 {
+		fmt.Printf("minishell/bash median ratio: %.2fx\n", float64(rows["minishell"].Median)/float64(bash))
+	}
+
+	if *csvPath != "" {
+		if err := writeBenchCSV(*csvPath, rows); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
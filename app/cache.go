@@ -0,0 +1,235 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// cachedRun is the part of a shell run that is stored in the result cache
+type cachedRun struct {
+	BinaryHash       string `json:"binary_hash"`
+	Stdout           string `json:"stdout"`
+	Stderr           string `json:"stderr"`
+	ExitCode         int    `json:"exit_code"`
+	FinalNewline     bool   `json:"final_newline"`
+	TrailingNewlines int    `json:"trailing_newlines"`
+	Signal           string `json:"signal,omitempty"`
+	Crash            string `json:"crash,omitempty"`
+	Combined         string `json:"combined,omitempty"`
+}
+
+// resultCache reuses shell runs across invocations while the shell binaries are unchanged
+type resultCache struct {
+	path   string
+	hashes map[string]string
+	// environment hashes the inherited environment and the bash startup file's contents,
+	// which shape every run without showing up in its input
+	environment string
+	entries     map[string]cachedRun
+	hits        int
+}
+
+// defaultCachePath returns where results are cached unless -cache-file says otherwise
+func defaultCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "mini_tester", "results.json")
+}
+
+// hashFile returns the hex SHA256 of a file's contents
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// openResultCache loads the cache at path for the given shells, dropping entries
+// recorded for binaries that have since changed
+func openResultCache(path, rcFile string, shellPaths ...string) (*resultCache, error) {
+	c := &resultCache{path: path, hashes: make(map[string]string), entries: make(map[string]cachedRun)}
+	environment, err := environmentHash(rcFile)
+	if err != nil {
+		return nil, err
+	}
+	c.environment = environment
+	current := make(map[string]bool)
+	for _, shellPath := range shellPaths {
+		hash, err := hashFile(shellPath)
+		if err != nil {
+			return nil, fmt.Errorf("error hashing %s: %v", shellPath, err)
+		}
+		c.hashes[shellPath] = hash
+		current[hash] = true
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading cache file: %v", err)
+	}
+	var entries map[string]cachedRun
+	if err := json.Unmarshal(data, &entries); err != nil {
+		// A corrupt cache is only a lost speedup; start over
+		return c, nil
+	}
+	for key, entry := range entries {
+		if current[entry.BinaryHash] {
+			c.entries[key] = entry
+		}
+	}
+	return c, nil
+}
+
+// environmentHash returns the hex SHA256 of the sorted environment and, if set, the
+// contents of the bash startup file
+func environmentHash(rcFile string) (string, error) {
+	env := os.Environ()
+	sort.Strings(env)
+	h := sha256.New()
+	for _, v := range env {
+		fmt.Fprintf(h, "%s\x00", v)
+	}
+	if rcFile != "" {
+		data, err := os.ReadFile(rcFile)
+		if err != nil {
+			return "", fmt.Errorf("error hashing %s: %v", rcFile, err)
+		}
+		fmt.Fprintf(h, "\x00%s", data)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// key identifies a run of a test by the shell binary, the environment and everything
+// that shapes its input
+func (c *resultCache) key(st *ShellTester, shellPath string, tc TestCase) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s", c.hashes[shellPath], c.environment)
+	writeInvocation(h, st, shellPath, tc)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeInvocation writes everything that shapes a shell's run of a test other than
+// the binary itself: its input, environment and how it is started
+func writeInvocation(h io.Writer, st *ShellTester, shellPath string, tc TestCase) {
+	fmt.Fprintf(h, "\x00%s\x00%t\x00%d\x00%d", shellInput(tc, st.sendsExit(tc)),
+		tc.ExpectedCombined != "", tc.TtyRows, tc.TtyCols)
+	if shellPath == st.bashPath {
+		fmt.Fprintf(h, "\x00%s", st.bashRCFile)
+		if st.dockerImage != "" {
+			fmt.Fprintf(h, "\x00docker:%s", st.dockerImage)
+		}
+	}
+	if st.forceLocale != "" {
+		fmt.Fprintf(h, "\x00LC_ALL=%s", st.forceLocale)
+	}
+	if st.dashC {
+		fmt.Fprintf(h, "\x00-c")
+	}
+	// Entries cached before trailing newlines were counted can't serve tests that check them
+	if tc.TrailingNewlineSignificant {
+		fmt.Fprintf(h, "\x00trailing-newlines")
+	}
+}
+
+// get returns the cached run of a test in a shell, if there is one
+func (c *resultCache) get(st *ShellTester, shellPath string, tc TestCase) (commandResult, bool) {
+	entry, ok := c.entries[c.key(st, shellPath, tc)]
+	if !ok {
+		return commandResult{}, false
+	}
+	return commandResult{
+		stdout:           entry.Stdout,
+		stderr:           entry.Stderr,
+		exitCode:         entry.ExitCode,
+		finalNewline:     entry.FinalNewline,
+		trailingNewlines: entry.TrailingNewlines,
+		signal:           entry.Signal,
+		crash:            entry.Crash,
+		combined:         entry.Combined,
+	}, true
+}
+
+// put stores a completed run; runs cut short by a timeout or output cap are not reused
+func (c *resultCache) put(st *ShellTester, shellPath string, tc TestCase, res commandResult) {
+	if res.timedOut || res.truncated {
+		return
+	}
+	c.entries[c.key(st, shellPath, tc)] = cachedRun{
+		BinaryHash:       c.hashes[shellPath],
+		Stdout:           res.stdout,
+		Stderr:           res.stderr,
+		ExitCode:         res.exitCode,
+		FinalNewline:     res.finalNewline,
+		TrailingNewlines: res.trailingNewlines,
+		Signal:           res.signal,
+		Crash:            res.crash,
+		Combined:         res.combined,
+	}
+}
+
+// save writes the cache back to disk
+func (c *resultCache) save() error {
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return fmt.Errorf("error encoding cache: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("error creating cache directory: %v", err)
+	}
+	if err := os.WriteFile(c.path, data, 0644); err != nil {
+		return fmt.Errorf("error writing cache file: %v", err)
+	}
+	return nil
+}
+
+// cacheable reports whether a test's runs can be reused from the result cache
+func (st *ShellTester) cacheable(tc TestCase) bool {
+	return st.cache != nil && st.reusable(tc)
+}
+
+// reusable reports whether a test's runs can stand in for fresh ones; repeated, timed
+// or process-inspecting runs need a real execution every time, and a printed pwd
+// names the run's own temporary directory
+func (st *ShellTester) reusable(tc TestCase) bool {
+	return tc.NondeterministicRuns <= 1 && tc.MinDurationMs == 0 && tc.ExpectedPwd == "" && st.retries == 0 &&
+		!st.timestamps && !st.checkLeftovers && st.stracePath == "" && !st.peakMemory
+}
+
+// runPair runs a test in bash and minishell, reusing cached runs only when both
+// are cached so the two always come from the same working directory
+func (st *ShellTester) runPair(tc TestCase) (bash, mini commandResult, cached bool) {
+	if st.cacheable(tc) {
+		b, okB := st.cache.get(st, st.bashPath, tc)
+		m, okM := st.cache.get(st, st.minishellPath, tc)
+		if okB && okM {
+			st.cache.hits++
+			return b, m, true
+		}
+	}
+
+	bash = st.runBash(tc)
+	mini = st.runCommand(st.minishellPath, tc)
+	if st.cacheable(tc) && !st.interrupted() {
+		st.cache.put(st, st.bashPath, tc, bash)
+		st.cache.put(st, st.minishellPath, tc, mini)
+	}
+	return bash, mini, false
+}
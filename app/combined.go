@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// combinedBuffer interleaves writes from stdout and stderr in the order they arrive
+type combinedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// Write appends p; it is safe to call from the stdout and stderr copiers at once
+func (c *combinedBuffer) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Write(p)
+}
+
+// String returns everything written so far
+func (c *combinedBuffer) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.String()
+}
+
+// sameOutputLines reports whether two outputs consist of the same lines in any order
+func sameOutputLines(a, b string) bool {
+	linesA, linesB := strings.Split(a, "\n"), strings.Split(b, "\n")
+	if len(linesA) != len(linesB) {
+		return false
+	}
+	sort.Strings(linesA)
+	sort.Strings(linesB)
+	return slices.Equal(linesA, linesB)
+}
+
+// tee makes w also write into the combined buffer
+func (c *combinedBuffer) tee(w io.Writer) io.Writer {
+	return io.MultiWriter(w, c)
+}
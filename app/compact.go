@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// firstLineDiff describes the first line where two outputs differ
+func firstLineDiff(want, got string) string {
+	wantLines, gotLines := strings.Split(want, "\n"), strings.Split(got, "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		w, g := "", ""
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g || i >= len(wantLines) || i >= len(gotLines) {
+			return fmt.Sprintf("line %d: %q vs %q", i+1, w, g)
+		}
+	}
+	return "outputs are equal"
+}
+
+// firstDifference summarizes the first reason a test failed in one line
+func firstDifference(r TestResult) string {
+	switch {
+	case r.Flaky:
+		passed := 0
+		for _, a := range r.Attempts {
+			if a.passed() {
+				passed++
+			}
+		}
+		return fmt.Sprintf("flaky: passed %d of %d attempts", passed, len(r.Attempts))
+	case r.MinishellTimedOut:
+		return "minishell timed out"
+	case r.MinishellOutputTruncated:
+		return "minishell produced excessive output"
+	case !r.OutputMatch:
+		return "stdout " + firstLineDiff(r.BashOutput, r.MinishellOutput) + " (bash vs minishell)"
+	case !r.ErrorMatch && r.ExpectNonEmptyError:
+		return fmt.Sprintf("stderr: expected an error from both shells, bash wrote %d bytes, minishell %d bytes",
+			len(r.BashError), len(r.MinishellError))
+	case !r.ErrorMatch:
+		return "stderr " + firstLineDiff(r.BashError, r.MinishellError) + " (bash vs minishell)"
+	case !r.ReturnCodeMatch:
+		return fmt.Sprintf("exit code: bash %d, minishell %d", r.BashReturnCode, r.MinishellReturnCode)
+	case !r.FinalNewlineMatch && r.BashFinalNewline == r.MinishellFinalNewline:
+		return fmt.Sprintf("trailing newlines: bash %d, minishell %d", r.BashTrailingNewlines, r.MinishellTrailingNewlines)
+	case !r.FinalNewlineMatch:
+		return fmt.Sprintf("final newline: bash %t, minishell %t", r.BashFinalNewline, r.MinishellFinalNewline)
+	case !r.ExpectedOutputMatch:
+		return "expected stdout " + firstLineDiff(r.ExpectedOutput, r.MinishellOutput)
+	case !r.ExpectedErrorMatch:
+		return "expected stderr " + firstLineDiff(r.ExpectedError, r.MinishellError)
+	case !r.ExpectedCodeMatch:
+		return fmt.Sprintf("expected exit code not met, minishell returned %d", r.MinishellReturnCode)
+	case !r.ExpectedSignalMatch:
+		return fmt.Sprintf("expected signal not received, minishell got %q", r.MinishellSignal)
+	case !r.ExpectedLinesMatch && len(r.LineMismatches) > 0:
+		m := r.LineMismatches[0]
+		return fmt.Sprintf("expected line %d: %q vs %q", m.Line, m.Expected, m.Got)
+	case !r.ExpectedLinesMatch:
+		return "expected lines not met"
+	case !r.MinDurationMet:
+		return fmt.Sprintf("minishell finished too quickly, in %dms", r.MinishellDurationMs)
+	case !r.ExpectedCombinedMatch:
+		return "expected combined output " + firstLineDiff(r.ExpectedCombined, r.MinishellCombined)
+	case !r.InterleavingMatch && sameOutputLines(r.BashMerged, r.MinishellMerged):
+		return "same lines, different stdout/stderr interleaving"
+	case !r.InterleavingMatch:
+		return "merged streams " + firstLineDiff(r.BashMerged, r.MinishellMerged) + " (bash vs minishell)"
+	case len(r.FailedProbes) > 0:
+		return "probe " + r.FailedProbes[0]
+	case len(r.FailedAssertions) > 0:
+		return "assertion failed: " + r.FailedAssertions[0]
+	case !r.PwdMatch:
+		return fmt.Sprintf("working directory: expected %q, bash %q, minishell %q", r.ExpectedPwd, r.BashPwd, r.MinishellPwd)
+	}
+	return ""
+}
+
+// writeCompact writes one numbered line per test in test order, with the first difference under failures
+func writeCompact(w io.Writer, testCases []TestCase, results map[string]TestResult) {
+	printed := make(map[string]bool)
+	for _, tc := range testCases {
+		result, ok := results[tc.resultKey()]
+		if !ok || printed[tc.resultKey()] {
+			continue
+		}
+		printed[tc.resultKey()] = true
+		if result.Accepted {
+			fmt.Fprintf(w, "PASS  %4d  %s (accepted difference)\n", result.Index, result.Description)
+			continue
+		}
+		if result.passed() {
+			fmt.Fprintf(w, "PASS  %4d  %s\n", result.Index, result.Description)
+			continue
+		}
+		fmt.Fprintf(w, "FAIL  %4d  %s\n", result.Index, result.Description)
+		if diff := firstDifference(result); diff != "" {
+			fmt.Fprintf(w, "            %s\n", diff)
+		}
+		for _, line := range firstDifferenceSnippet(result) {
+			fmt.Fprintf(w, "            %s\n", line)
+		}
+	}
+}
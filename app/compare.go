@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// outputComparator decides whether minishell's stdout is equivalent to bash's for a test
+type outputComparator func(tc TestCase, bash, mini string) bool
+
+// outputComparators maps the comparator names a test can select to their implementation
+var outputComparators = map[string]outputComparator{
+	"":                  exactComparator,
+	"exact":             exactComparator,
+	"numeric-tolerance": numericToleranceComparator,
+	"env-set":           envSetComparator,
+}
+
+// validateComparator reports an error for a comparator name that doesn't exist
+func validateComparator(name string) error {
+	if _, ok := outputComparators[name]; !ok {
+		return fmt.Errorf("unknown comparator %q", name)
+	}
+	return nil
+}
+
+// errorComparators maps the stderr comparator names a test can select to their implementation
+var errorComparators = map[string]func(bash, mini string) bool{
+	"":                  func(bash, mini string) bool { return bash == mini },
+	"exact":             func(bash, mini string) bool { return bash == mini },
+	"command-not-found": func(bash, mini string) bool { return normalizeNotFound(bash) == normalizeNotFound(mini) },
+}
+
+// validateErrorComparator reports an error for a stderr comparator name that doesn't exist
+func validateErrorComparator(name string) error {
+	if _, ok := errorComparators[name]; !ok {
+		return fmt.Errorf("unknown error_comparator %q", name)
+	}
+	return nil
+}
+
+// notFoundPattern matches "bash: line 1: foo: command not found", "minishell: foo: command not found" and the like
+var notFoundPattern = regexp.MustCompile(`(?m)^.*?:\s*(?:line \d+:\s*)?([^:\n]+):\s*command not found\s*$`)
+
+// normalizeNotFound drops the program name and line number prefix from command not found
+// messages, so only the command name and the message are compared
+func normalizeNotFound(stderr string) string {
+	return notFoundPattern.ReplaceAllString(stderr, "$1: command not found")
+}
+
+// errorPrefixToken replaces the program name that starts error messages under -normalize-error-prefix
+const errorPrefixToken = "shell: "
+
+// replaceErrorPrefix replaces the "name: " or "name: line 3: " prefix that starts
+// error lines from any of the named programs with errorPrefixToken
+func replaceErrorPrefix(stderr string, names ...string) string {
+	for _, name := range names {
+		prefix := regexp.MustCompile(`(?m)^` + regexp.QuoteMeta(name) + `:(?: line \d+:)? `)
+		stderr = prefix.ReplaceAllLiteralString(stderr, errorPrefixToken)
+	}
+	return stderr
+}
+
+// exactComparator requires the outputs to be identical
+func exactComparator(_ TestCase, bash, mini string) bool {
+	return bash == mini
+}
+
+// envSetComparator treats both outputs as sets of NAME=value lines, ignoring their
+// order and the test's ignore_keys
+func envSetComparator(tc TestCase, bash, mini string) bool {
+	return len(envVarDifferences(bash, mini, tc.IgnoreKeys)) == 0
+}
+
+// numberPattern matches integers and decimals with an optional exponent
+var numberPattern = regexp.MustCompile(`[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?`)
+
+// numericToleranceComparator requires the text around numbers to match exactly and
+// each pair of numbers to be within the test's absolute or relative tolerance
+func numericToleranceComparator(tc TestCase, bash, mini string) bool {
+	bashNums := numberPattern.FindAllStringIndex(bash, -1)
+	miniNums := numberPattern.FindAllStringIndex(mini, -1)
+	if len(bashNums) != len(miniNums) {
+		return false
+	}
+	if numberPattern.ReplaceAllString(bash, "#") != numberPattern.ReplaceAllString(mini, "#") {
+		return false
+	}
+
+	for i := range bashNums {
+		b, errB := strconv.ParseFloat(bash[bashNums[i][0]:bashNums[i][1]], 64)
+		m, errM := strconv.ParseFloat(mini[miniNums[i][0]:miniNums[i][1]], 64)
+		if errB != nil || errM != nil {
+			return false
+		}
+		diff := math.Abs(b - m)
+		if diff > tc.AbsTolerance && diff > tc.RelTolerance*math.Max(math.Abs(b), math.Abs(m)) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterIgnoredLines drops lines matching any of the patterns; invalid patterns are
+// rejected when tests load, so they are skipped here
+func filterIgnoredLines(output string, patterns []string) string {
+	var res []*regexp.Regexp
+	for _, pattern := range patterns {
+		if re, err := regexp.Compile(pattern); err == nil {
+			res = append(res, re)
+		}
+	}
+
+	lines := strings.Split(output, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		ignored := false
+		for _, re := range res {
+			if re.MatchString(line) {
+				ignored = true
+				break
+			}
+		}
+		if !ignored {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// tailLines returns the last n lines of output, or all of it if it has fewer
+func tailLines(output string, n int) string {
+	lines := strings.Split(output, "\n")
+	if len(lines) <= n {
+		return output
+	}
+	return strings.Join(lines[len(lines)-n:], "\n")
+}
+
+// outputLine returns 1-based line n of output, or "" if it has fewer lines
+func outputLine(output string, n int) string {
+	lines := strings.Split(output, "\n")
+	if n < 1 || n > len(lines) {
+		return ""
+	}
+	return lines[n-1]
+}
+
+// maskLines blanks the lines a test asserts individually so the rest can be compared with bash
+func maskLines(output string, expected map[int]string) string {
+	if len(expected) == 0 {
+		return output
+	}
+	lines := strings.Split(output, "\n")
+	for n := range expected {
+		if n >= 1 && n <= len(lines) {
+			lines[n-1] = ""
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// LineMismatch is an expected_lines entry the compared output didn't match
+type LineMismatch struct {
+	Line     int    `json:"line"`
+	Expected string `json:"expected"`
+	Got      string `json:"got"`
+}
+
+// lineMismatches returns, in line order, the expected lines whose content differs in output
+func lineMismatches(output string, expected map[int]string) []LineMismatch {
+	var mismatched []LineMismatch
+	for n, want := range expected {
+		if got := outputLine(output, n); got != want {
+			mismatched = append(mismatched, LineMismatch{Line: n, Expected: want, Got: got})
+		}
+	}
+	sort.Slice(mismatched, func(i, j int) bool { return mismatched[i].Line < mismatched[j].Line })
+	return mismatched
+}
+
+// validateExpectedLines reports an error for line numbers below 1
+func validateExpectedLines(expected map[int]string) error {
+	for n := range expected {
+		if n < 1 {
+			return fmt.Errorf("expected_lines: line numbers start at 1, got %d", n)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// convertTestFile converts a test file's contents from one format to the other,
+// keeping every field and its order. The file is checked with the same parsing
+// -tests uses, so a file that converts is one the tester accepts.
+func convertTestFile(data []byte, from, baseDir string) ([]byte, error) {
+	var doc interface{}
+	var err error
+	if from == "yaml" {
+		if doc, err = parseYAML(data); err != nil {
+			err = fmt.Errorf("error parsing YAML: %v", err)
+		}
+	} else {
+		doc, err = decodeOrderedJSON(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	m, ok := doc.(*orderedMap)
+	if !ok {
+		return nil, fmt.Errorf("a test file must be an object with test_cases or suites")
+	}
+
+	var jsonData bytes.Buffer
+	enc := json.NewEncoder(&jsonData)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return nil, fmt.Errorf("error creating JSON output: %v", err)
+	}
+	if _, err := parseTestCases(jsonData.Bytes(), baseDir); err != nil {
+		return nil, fmt.Errorf("invalid test file: %v", err)
+	}
+
+	if from == "yaml" {
+		return jsonData.Bytes(), nil
+	}
+	out, err := marshalYAML(m)
+	if err != nil {
+		return nil, fmt.Errorf("error creating YAML output: %v", err)
+	}
+	return out, nil
+}
+
+// runConvert implements the convert subcommand, which rewrites a JSON test file as
+// YAML or a YAML one as JSON
+func runConvert(args []string) {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	outputPath := fs.String("o", stdioPath, "Path to write the converted file to")
+	to := fs.String("to", "", "Format to convert to, json or yaml (default: the other one than the input's extension)")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		_, _ = fmt.Fprintf(os.Stderr, "Usage: convert [-to json|yaml] [-o FILE] FILE\n")
+		os.Exit(1)
+	}
+	inputPath := fs.Arg(0)
+
+	from := testFileFormat(inputPath)
+	switch *to {
+	case "":
+		if inputPath == stdioPath {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: -to is required when reading from stdin\n")
+			os.Exit(1)
+		}
+	case "json":
+		from = "yaml"
+	case "yaml":
+		from = "json"
+	default:
+		_, _ = fmt.Fprintf(os.Stderr, "Error: -to must be json or yaml, got %q\n", *to)
+		os.Exit(1)
+	}
+
+	var data []byte
+	var err error
+	if inputPath == stdioPath {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(inputPath)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: error reading file: %v\n", err)
+		os.Exit(1)
+	}
+
+	out, err := convertTestFile(data, from, filepath.Dir(inputPath))
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %s: %v\n", inputPath, err)
+		os.Exit(1)
+	}
+	if *outputPath == stdioPath {
+		_, _ = os.Stdout.Write(out)
+		return
+	}
+	if err := os.WriteFile(*outputPath, out, 0644); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: error writing output file: %v\n", err)
+		os.Exit(1)
+	}
+}
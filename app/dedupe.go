@@ -0,0 +1,47 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// bashRun is a bash run kept for later tests in the run making the same invocation
+type bashRun struct {
+	// dir is the temporary directory the run happened in
+	dir string
+	res commandResult
+}
+
+// runBash runs a test in bash, reusing an identical earlier invocation from this run.
+// Tests writing to absolute paths are always run, since their output can depend on
+// what earlier tests left there.
+func (st *ShellTester) runBash(tc TestCase) commandResult {
+	if !st.reusable(tc) || len(outputFiles(tc.Command)) > 0 {
+		return st.runCommand(st.bashPath, tc)
+	}
+
+	h := sha256.New()
+	writeInvocation(h, st, st.bashPath, tc)
+	key := hex.EncodeToString(h.Sum(nil))
+	if prev, ok := st.bashRuns[key]; ok && !mentionsDir(prev.res, prev.dir) {
+		st.bashReuses++
+		return prev.res
+	}
+
+	res := st.runCommand(st.bashPath, tc)
+	if !res.timedOut && !res.truncated && !st.interrupted() {
+		if st.bashRuns == nil {
+			st.bashRuns = make(map[string]bashRun)
+		}
+		st.bashRuns[key] = bashRun{dir: tc.tmpDir, res: res}
+	}
+	return res
+}
+
+// mentionsDir reports whether a run printed its temporary directory, which a later
+// test's run would print differently
+func mentionsDir(res commandResult, dir string) bool {
+	return dir != "" && (strings.Contains(res.stdout, dir) || strings.Contains(res.stderr, dir) ||
+		strings.Contains(res.combined, dir))
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// maxCharDiffBytes is the combined size above which outputs are diffed line by line,
+// since a character diff of large dissimilar outputs can take quadratic time
+const maxCharDiffBytes = 64 * 1024
+
+// diffTimeout bounds how long a single diff may run before returning a coarser result
+const diffTimeout = 2 * time.Second
+
+// newDiffer returns a diff-match-patch instance with a bounded diff runtime
+func newDiffer() *diffmatchpatch.DiffMatchPatch {
+	dmp := diffmatchpatch.New()
+	dmp.DiffTimeout = diffTimeout
+	return dmp
+}
+
+// prettyDiff renders the differences from a to b, falling back to a line diff with
+// a note when the outputs are too large for a character diff
+func prettyDiff(dmp *diffmatchpatch.DiffMatchPatch, a, b string) string {
+	if len(a)+len(b) <= maxCharDiffBytes {
+		return dmp.DiffPrettyText(dmp.DiffMain(a, b, false))
+	}
+	charsA, charsB, lines := dmp.DiffLinesToChars(a, b)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(charsA, charsB, false), lines)
+	return dmp.DiffPrettyText(diffs) +
+		fmt.Sprintf("\n(character diff skipped for size: %d bytes vs %d bytes; showing changed lines)", len(a), len(b))
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// checkDocker reports an error if the docker client needed by -docker-bash isn't installed
+func checkDocker() error {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return fmt.Errorf("-docker-bash needs the docker command, which was not found in PATH")
+	}
+	return nil
+}
+
+// dockerBashArgs returns the docker arguments that run bash with args in image. The
+// test directory and rcfile are mounted at their own paths so commands and BASH_ENV
+// refer to them unchanged, and env sets the test's variables inside the container.
+func dockerBashArgs(image, dir, rcFile string, env, args []string) []string {
+	dockerArgs := []string{"run", "-i", "--rm"}
+	if dir != "" {
+		dockerArgs = append(dockerArgs, "-v", dir+":"+dir, "-w", dir)
+	}
+	if rcFile != "" {
+		dockerArgs = append(dockerArgs, "-v", rcFile+":"+rcFile+":ro")
+	}
+	for _, kv := range env {
+		dockerArgs = append(dockerArgs, "-e", kv)
+	}
+	dockerArgs = append(dockerArgs, image, "bash")
+	return append(dockerArgs, args...)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// defaultEnvIgnore lists variables that legitimately differ between shells in env output
+const defaultEnvIgnore = "SHLVL,_,PWD"
+
+// parseNameList splits a comma-separated list of names into a set
+func parseNameList(list string) map[string]bool {
+	names := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// printsEnv reports whether a command starts by printing the environment with env or export
+func printsEnv(command string) bool {
+	fields := strings.Fields(command)
+	if len(fields) == 0 || (fields[0] != "env" && fields[0] != "export") {
+		return false
+	}
+	return len(fields) == 1 || fields[1] == "|" || fields[1] == ">" || fields[1] == ">>"
+}
+
+// envLineName returns the variable name of an env or export output line
+func envLineName(line string) string {
+	line = strings.TrimPrefix(line, "declare -x ")
+	name, _, _ := strings.Cut(line, "=")
+	return name
+}
+
+// filterEnvLines drops lines for ignored variables from env or export output
+func filterEnvLines(output string, ignore map[string]bool) string {
+	if len(ignore) == 0 {
+		return output
+	}
+	lines := strings.Split(output, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !ignore[envLineName(line)] {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// parseEnvVars reads env or export output into a map of variable name to value,
+// leaving out blank lines and the ignored names
+func parseEnvVars(output string, ignore []string) map[string]string {
+	vars := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		name := envLineName(line)
+		_, value, _ := strings.Cut(strings.TrimPrefix(line, "declare -x "), "=")
+		vars[name] = value
+	}
+	for _, name := range ignore {
+		delete(vars, name)
+	}
+	return vars
+}
+
+// envVarDifferences describes, in name order, each variable missing from one output
+// or set to a different value in the other
+func envVarDifferences(bash, mini string, ignore []string) []string {
+	bashVars, miniVars := parseEnvVars(bash, ignore), parseEnvVars(mini, ignore)
+	var names []string
+	for name := range bashVars {
+		names = append(names, name)
+	}
+	for name := range miniVars {
+		if _, ok := bashVars[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var diffs []string
+	for _, name := range names {
+		b, inBash := bashVars[name]
+		m, inMini := miniVars[name]
+		switch {
+		case !inMini:
+			diffs = append(diffs, fmt.Sprintf("%s: only in bash (%s)", name, b))
+		case !inBash:
+			diffs = append(diffs, fmt.Sprintf("%s: only in minishell (%s)", name, m))
+		case b != m:
+			diffs = append(diffs, fmt.Sprintf("%s: bash=%s minishell=%s", name, b, m))
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultExitCodeMap treats 255, the status older bash gave "exit" with a non-numeric
+// argument and that many minishells copy, as bash's current 2
+const defaultExitCodeMap = "255=2"
+
+// parseExitCodeMap reads comma-separated from=to pairs of exit codes
+func parseExitCodeMap(list string) (map[int]int, error) {
+	codes := make(map[int]int)
+	for _, pair := range strings.Split(list, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(pair, "=")
+		f, errF := strconv.Atoi(strings.TrimSpace(from))
+		t, errT := strconv.Atoi(strings.TrimSpace(to))
+		if !ok || errF != nil || errT != nil {
+			return nil, fmt.Errorf("invalid exit code mapping %q, want from=to", pair)
+		}
+		codes[f] = t
+	}
+	return codes, nil
+}
+
+// sameExitCode compares exit codes after -normalize-exit-codes maps both
+func (st *ShellTester) sameExitCode(a, b int) bool {
+	if to, ok := st.exitCodeMap[a]; ok {
+		a = to
+	}
+	if to, ok := st.exitCodeMap[b]; ok {
+		b = to
+	}
+	return a == b
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// explainContext is how many bytes either side of the first difference are hex dumped
+const explainContext = 16
+
+// firstDifferingByte returns the offset of the first byte where a and b differ, or -1 if they are equal
+func firstDifferingByte(a, b string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	if len(a) != len(b) {
+		return min(len(a), len(b))
+	}
+	return -1
+}
+
+// hexWindow dumps the bytes of s around offset
+func hexWindow(s string, offset int) string {
+	start := max(offset-explainContext, 0)
+	end := min(offset+explainContext, len(s))
+	if start >= end {
+		return "  (no bytes)\n"
+	}
+	return hex.Dump([]byte(s[start:end]))
+}
+
+// explainStream prints a breakdown of how one stream differs between the shells
+func explainStream(name, bash, mini string) {
+	fmt.Printf("\n%s\n%s\n", name, strings.Repeat("-", len(name)))
+	fmt.Printf("Length: bash=%d bytes, minishell=%d bytes\n", len(bash), len(mini))
+	offset := firstDifferingByte(bash, mini)
+	if offset < 0 {
+		fmt.Println("Identical")
+		return
+	}
+
+	fmt.Printf("Diff:\n%s\n", prettyDiff(newDiffer(), bash, mini))
+	fmt.Printf("First differing byte at offset %d\n", offset)
+	fmt.Printf("bash around offset %d:\n%s", max(offset-explainContext, 0), hexWindow(bash, offset))
+	fmt.Printf("minishell around offset %d:\n%s", max(offset-explainContext, 0), hexWindow(mini, offset))
+
+	switch {
+	case strings.Join(strings.Fields(bash), " ") == strings.Join(strings.Fields(mini), " "):
+		fmt.Println("Explained by: whitespace only")
+	case strings.ReplaceAll(bash, "\n", "") == strings.ReplaceAll(mini, "\n", ""):
+		fmt.Println("Explained by: line breaks only")
+	case (hasANSI(bash) || hasANSI(mini)) && stripANSI(bash) == stripANSI(mini):
+		fmt.Printf("Explained by: ANSI styling only (%s)\n", compareANSI(bash, mini))
+	default:
+		fmt.Println("Explained by: content differs")
+	}
+}
+
+// runExplain implements the explain subcommand
+func runExplain(args []string) {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	bashPath := fs.String("bash", "/bin/bash", "Path to Bash executable")
+	minishellPath := fs.String("minishell", "./minishell", "Path to Minishell executable")
+	timeout := fs.Duration("timeout", defaultTimeout, "Timeout for each shell")
+	_ = fs.Parse(args)
+
+	command := strings.Join(fs.Args(), " ")
+	if command == "" {
+		_, _ = fmt.Fprintf(os.Stderr, "Usage: explain [flags] COMMAND\n")
+		os.Exit(1)
+	}
+
+	tester, err := NewShellTester(*bashPath, *minishellPath)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	tester.timeout = *timeout
+
+	tc := TestCase{Command: command, Description: command}
+	if dir, err := newTestDir(); err == nil {
+		defer os.RemoveAll(dir)
+		tc.tmpDir = dir
+	}
+	bash := tester.runCommand(tester.bashPath, tc)
+	mini := tester.runCommand(tester.minishellPath, tc)
+
+	fmt.Printf("Command: %s\n", command)
+	fmt.Printf("Exit code: bash=%d, minishell=%d", bash.exitCode, mini.exitCode)
+	if bash.exitCode != mini.exitCode {
+		fmt.Print("  (differs)")
+	}
+	fmt.Println()
+	if bash.signal != "" || mini.signal != "" {
+		fmt.Printf("Signal: bash=%q, minishell=%q\n", bash.signal, mini.signal)
+	}
+	if bash.timedOut || mini.timedOut {
+		fmt.Printf("Timed out: bash=%t, minishell=%t\n", bash.timedOut, mini.timedOut)
+	}
+	if bash.finalNewline != mini.finalNewline {
+		fmt.Printf("Trailing newline on stdout: bash=%t, minishell=%t\n", bash.finalNewline, mini.finalNewline)
+	}
+
+	explainStream("stdout", bash.stdout, mini.stdout)
+	explainStream("stderr", bash.stderr, mini.stderr)
+}
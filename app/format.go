@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// formatWriter renders a finished run in one output format
+type formatWriter func(w io.Writer, rep *runReport) error
+
+// writerReporter adapts a formatWriter to the Reporter interface
+type writerReporter struct {
+	w     io.Writer
+	write formatWriter
+	run   *runReport
+}
+
+// Report writes the results with the adapted formatWriter
+func (r *writerReporter) Report(results map[string]TestResult, summary Summary) error {
+	return r.write(r.w, r.run.with(results, summary))
+}
+
+// newReporterFunc builds the Reporter for one format, writing to w
+type newReporterFunc func(w io.Writer, run *runReport) Reporter
+
+// fromWriter makes a newReporterFunc out of a formatWriter
+func fromWriter(write formatWriter) newReporterFunc {
+	return func(w io.Writer, run *runReport) Reporter {
+		return &writerReporter{w: w, write: write, run: run}
+	}
+}
+
+// textStyle makes a newReporterFunc for the text report in the given style
+func textStyle(style string) newReporterFunc {
+	return func(w io.Writer, run *runReport) Reporter {
+		return &textReporter{w: w, style: style, run: run}
+	}
+}
+
+// formatReporters are the formats accepted by -format
+var formatReporters = map[string]newReporterFunc{
+	"text":    textStyle("text"),
+	"compact": textStyle("compact"),
+	"table":   textStyle("table"),
+	"json":    func(w io.Writer, run *runReport) Reporter { return &jsonReporter{w: w, run: run} },
+	"csv":     fromWriter(writeCSVReport),
+	"tap":     fromWriter(writeTAPReport),
+	"md":      fromWriter(writeMarkdownReport),
+	"junit":   fromWriter(writeJUnitReport),
+	"html":    fromWriter(writeHTMLReport),
+	"github":  fromWriter(writeGitHubReport),
+}
+
+// formatTarget is one entry of -format: a format and where to write it ("" is stdout)
+type formatTarget struct {
+	Name string
+	Path string
+}
+
+// formatNames returns the accepted format names, sorted
+func formatNames() []string {
+	names := make([]string, 0, len(formatReporters))
+	for name := range formatReporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseFormats parses a comma-separated list of name or name=path entries
+func parseFormats(spec string) ([]formatTarget, error) {
+	var targets []formatTarget
+	toStdout := 0
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, path, _ := strings.Cut(entry, "=")
+		if _, ok := formatReporters[name]; !ok {
+			return nil, fmt.Errorf("unknown format %q (want one of %s)", name, strings.Join(formatNames(), ", "))
+		}
+		if path == "" || path == stdioPath {
+			path = ""
+			toStdout++
+		}
+		targets = append(targets, formatTarget{Name: name, Path: path})
+	}
+	if toStdout > 1 {
+		return nil, fmt.Errorf("only one format can be written to stdout")
+	}
+	return targets, nil
+}
+
+// textFormats are the human-readable formats, which saved-file notices can follow on stdout
+var textFormats = map[string]bool{"text": true, "compact": true, "table": true}
+
+// writeFormats writes the run in every requested format
+func writeFormats(targets []formatTarget, rep *runReport) error {
+	// Only add notices to stdout when a person is reading it, not a TAP or JSON consumer
+	announce := false
+	for _, t := range targets {
+		if t.Path == "" && textFormats[t.Name] {
+			announce = true
+		}
+	}
+	for _, t := range targets {
+		if t.Path == "" {
+			if err := formatReporters[t.Name](os.Stdout, rep).Report(rep.Results, rep.Summary); err != nil {
+				return fmt.Errorf("error writing %s output: %v", t.Name, err)
+			}
+			continue
+		}
+		file, err := os.Create(t.Path)
+		if err != nil {
+			return fmt.Errorf("error writing %s output: %v", t.Name, err)
+		}
+		err = formatReporters[t.Name](file, rep).Report(rep.Results, rep.Summary)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("error writing %s output: %v", t.Name, err)
+		}
+		if announce {
+			fmt.Printf("\n%s results saved to %s\n", t.Name, t.Path)
+		}
+	}
+	return nil
+}
+
+// reportRow is one test's result with the command it ran and its colored diff
+type reportRow struct {
+	Command string
+	Result  TestResult
+	Diff    string
+}
+
+// reportRows lists results in test order
+func reportRows(rep *runReport) []reportRow {
+	var rows []reportRow
+	for _, tc := range rep.TestCases {
+		result, ok := rep.Results[tc.resultKey()]
+		if !ok {
+			continue
+		}
+		rows = append(rows, reportRow{Command: tc.Command, Result: result, Diff: rep.Differences[tc.resultKey()]})
+	}
+	return rows
+}
+
+// Escape sequences DiffPrettyText wraps deleted and inserted text in
+const (
+	diffDelete = "\x1b[31m"
+	diffInsert = "\x1b[32m"
+	diffReset  = "\x1b[0m"
+)
+
+// markDiff replaces a diff's colors with markers around deleted and inserted
+// text, passing the text in between through escape
+func markDiff(diff, delOpen, delClose, insOpen, insClose string, escape func(string) string) string {
+	var sb strings.Builder
+	closing := ""
+	for diff != "" {
+		i := strings.Index(diff, "\x1b[")
+		if i < 0 {
+			sb.WriteString(escape(diff))
+			break
+		}
+		sb.WriteString(escape(diff[:i]))
+		diff = diff[i:]
+		switch {
+		case strings.HasPrefix(diff, diffDelete):
+			sb.WriteString(delOpen)
+			closing, diff = delClose, diff[len(diffDelete):]
+		case strings.HasPrefix(diff, diffInsert):
+			sb.WriteString(insOpen)
+			closing, diff = insClose, diff[len(diffInsert):]
+		case strings.HasPrefix(diff, diffReset):
+			sb.WriteString(closing)
+			closing, diff = "", diff[len(diffReset):]
+		default:
+			// Colors in the shells' own output carry no diff meaning
+			j := strings.IndexFunc(diff[2:], func(r rune) bool { return r >= 0x40 && r <= 0x7e })
+			if j < 0 {
+				diff = ""
+			} else {
+				diff = diff[2+j+1:]
+			}
+		}
+	}
+	return sb.String()
+}
+
+// plainDiff marks a diff's deletions as [-text-] and insertions as {+text+}
+func plainDiff(diff string) string {
+	return markDiff(diff, "[-", "-]", "{+", "+}", stripANSI)
+}
+
+// statusOf returns a result's one-word status
+func statusOf(r TestResult) string {
+	switch {
+	case r.Accepted:
+		return "accepted"
+	case r.passed():
+		return "pass"
+	}
+	return "fail"
+}
+
+// writeCSVReport writes one row per test
+func writeCSVReport(w io.Writer, rep *runReport) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"description", "command", "status", "bash_return_code", "minishell_return_code", "first_difference"})
+	for _, row := range reportRows(rep) {
+		r := row.Result
+		_ = cw.Write([]string{r.Description, row.Command, statusOf(r),
+			strconv.Itoa(r.BashReturnCode), strconv.Itoa(r.MinishellReturnCode), firstDifference(r)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeTAPReport writes the run as a Test Anything Protocol stream
+func writeTAPReport(w io.Writer, rep *runReport) error {
+	var sb strings.Builder
+	rows := reportRows(rep)
+	fmt.Fprintf(&sb, "TAP version 13\n1..%d\n", len(rows)+len(rep.Skipped))
+	n := 0
+	for _, row := range rows {
+		n++
+		r := row.Result
+		if !r.passed() {
+			fmt.Fprintf(&sb, "not ok %d - %s\n", n, r.Description)
+			if diff := firstDifference(r); diff != "" {
+				fmt.Fprintf(&sb, "  ---\n  message: %q\n  ...\n", diff)
+			}
+			continue
+		}
+		fmt.Fprintf(&sb, "ok %d - %s\n", n, r.Description)
+	}
+	for _, sk := range rep.Skipped {
+		n++
+		fmt.Fprintf(&sb, "ok %d - %s # SKIP %s\n", n, sk.Description, sk.Reason)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// markdownEscape keeps a value from breaking a Markdown table cell
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+}
+
+// writeMarkdownReport writes a summary table followed by the diff of each failure
+func writeMarkdownReport(w io.Writer, rep *runReport) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Test Summary (%d/%d passed)\n\n", rep.Summary.PassedTests, rep.Summary.TotalTests)
+	sb.WriteString("| Test | Command | Status | Bash RC | Minishell RC |\n|---|---|---|---|---|\n")
+	rows := reportRows(rep)
+	for _, row := range rows {
+		r := row.Result
+		fmt.Fprintf(&sb, "| %s | `%s` | %s | %d | %d |\n", markdownEscape(r.Description),
+			markdownEscape(row.Command), strings.ToUpper(statusOf(r)), r.BashReturnCode, r.MinishellReturnCode)
+	}
+	for _, row := range rows {
+		if row.Diff == "" {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n## %s\n\n```\n%s\n```\n", row.Result.Description, plainDiff(row.Diff))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// junitTestSuite is the root element of a JUnit XML report
+type junitTestSuite struct {
+	XMLName  xml.Name        `xml:"testsuite"`
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase is one test in a JUnit XML report
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+// junitFailure holds a failing test's first difference and full diff
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// junitSkipped marks a test skipped by -skip-file
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// writeJUnitReport writes the run as JUnit XML for CI test report viewers
+func writeJUnitReport(w io.Writer, rep *runReport) error {
+	suite := junitTestSuite{
+		Name:     "minishell",
+		Tests:    rep.Summary.TotalTests + len(rep.Skipped),
+		Failures: rep.Summary.FailedTests,
+		Skipped:  len(rep.Skipped),
+		Time:     fmt.Sprintf("%.3f", rep.Elapsed.Seconds()),
+	}
+	for _, row := range reportRows(rep) {
+		tc := junitTestCase{Name: row.Result.Description, ClassName: row.Result.Suite}
+		if !row.Result.passed() {
+			tc.Failure = &junitFailure{Message: firstDifference(row.Result), Body: plainDiff(row.Diff)}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	for _, sk := range rep.Skipped {
+		suite.Cases = append(suite.Cases, junitTestCase{Name: sk.Description, Skipped: &junitSkipped{Message: sk.Reason}})
+	}
+
+	data, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error creating JUnit output: %v", err)
+	}
+	_, err = w.Write(append([]byte(xml.Header), append(data, '\n')...))
+	return err
+}
+
+// writeHTMLReport writes a standalone page with a row per test and each failure's diff
+func writeHTMLReport(w io.Writer, rep *runReport) error {
+	var sb strings.Builder
+	title := fmt.Sprintf("Test Summary (%d/%d passed)", rep.Summary.PassedTests, rep.Summary.TotalTests)
+	fmt.Fprintf(&sb, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", title)
+	sb.WriteString("<style>body{font-family:sans-serif}td,th{padding:2px 8px;text-align:left}" +
+		".pass{color:green}.fail{color:red}.accepted{color:olive}pre{background:#f4f4f4;padding:8px}del{background:#fdd}ins{background:#dfd}</style>\n</head>\n<body>\n")
+	fmt.Fprintf(&sb, "<h1>%s</h1>\n<table>\n<tr><th>Test</th><th>Command</th><th>Status</th><th>Bash RC</th><th>Minishell RC</th></tr>\n", title)
+	rows := reportRows(rep)
+	for _, row := range rows {
+		r := row.Result
+		status := statusOf(r)
+		fmt.Fprintf(&sb, "<tr><td>%s</td><td><code>%s</code></td><td class=\"%s\">%s</td><td>%d</td><td>%d</td></tr>\n",
+			html.EscapeString(r.Description), html.EscapeString(row.Command), status, strings.ToUpper(status),
+			r.BashReturnCode, r.MinishellReturnCode)
+	}
+	sb.WriteString("</table>\n")
+	for _, row := range rows {
+		if row.Diff == "" {
+			continue
+		}
+		fmt.Fprintf(&sb, "<h2>%s</h2>\n<pre>%s</pre>\n", html.EscapeString(row.Result.Description),
+			markDiff(row.Diff, "<del>", "</del>", "<ins>", "</ins>", func(s string) string { return html.EscapeString(stripANSI(s)) }))
+	}
+	sb.WriteString("</body>\n</html>\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
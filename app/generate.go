@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// historyTimestamp matches the "#1700000000" lines bash writes when HISTTIMEFORMAT is set
+var historyTimestamp = regexp.MustCompile(`^#\d+$`)
+
+// loadHistory reads unique commands from a bash history file or newline-delimited command list
+func loadHistory(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading history file: %v", err)
+	}
+	defer file.Close()
+
+	var commands []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || historyTimestamp.MatchString(line) || seen[line] {
+			continue
+		}
+		seen[line] = true
+		commands = append(commands, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading history file: %v", err)
+	}
+
+	return commands, nil
+}
+
+// generateTestCases runs each command through bash and records what it did as expectations
+func (st *ShellTester) generateTestCases(commands []string) []TestCase {
+	testCases := make([]TestCase, 0, len(commands))
+	for _, command := range commands {
+		tc := TestCase{Command: command, Description: command}
+		res := st.runCommand(st.bashPath, tc)
+		if res.timedOut {
+			_, _ = fmt.Fprintf(os.Stderr, "Warning: %q timed out in bash, leaving it without expectations\n", command)
+			testCases = append(testCases, tc)
+			continue
+		}
+		tc.ExpectedOutput = res.stdout
+		tc.ExpectedError = res.stderr
+		tc.ExpectedCode = res.exitCode
+		testCases = append(testCases, tc)
+	}
+	return testCases
+}
+
+// runGenerate implements the generate subcommand
+func runGenerate(args []string) {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	bashPath := fs.String("bash", "/bin/bash", "Path to Bash executable")
+	historyPath := fs.String("history", "", "Path to a bash history file or newline-delimited command list")
+	outputPath := fs.String("output", "generated_test_cases.json", "Path to write the generated test cases, or - for stdout")
+	timeout := fs.Duration("timeout", defaultTimeout, "Per-command timeout")
+	force := fs.Bool("force", false, "Overwrite the output file if it exists")
+	_ = fs.Parse(args)
+
+	if *historyPath == "" {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: -history is required\n")
+		os.Exit(1)
+	}
+	if _, err := os.Stat(*bashPath); os.IsNotExist(err) {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: bash executable not found at %s\n", *bashPath)
+		os.Exit(1)
+	}
+	toStdout := *outputPath == stdioPath
+	if _, err := os.Stat(*outputPath); err == nil && !*force && !toStdout {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %s already exists (use -force to overwrite)\n", *outputPath)
+		os.Exit(1)
+	}
+
+	commands, err := loadHistory(*historyPath)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error loading history: %v\n", err)
+		os.Exit(1)
+	}
+
+	tester := &ShellTester{bashPath: *bashPath, timeout: *timeout}
+	jsonData, err := json.MarshalIndent(TestCases{Version: schemaVersion, Tests: tester.generateTestCases(commands)}, "", "  ")
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error creating JSON output: %v\n", err)
+		os.Exit(1)
+	}
+	if toStdout {
+		fmt.Println(string(jsonData))
+		return
+	}
+	if err := os.WriteFile(*outputPath, jsonData, 0644); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error writing output file: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Wrote %d test cases to %s; review them before using it as a suite\n", len(commands), *outputPath)
+}
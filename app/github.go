@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxAnnotationDiffLines is how many lines of a failure's diff go into its annotation
+const maxAnnotationDiffLines = 20
+
+// githubEscaper escapes the message of a GitHub Actions workflow command
+var githubEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+
+// githubPropertyEscaper escapes a property value of a workflow command, which also ends at ':' and ','
+var githubPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+
+// testLine returns the 1-based line of a test's description in its file, or 0 if it can't be found
+func testLine(data []byte, description string) int {
+	var quoted bytes.Buffer
+	enc := json.NewEncoder(&quoted)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(description); err != nil {
+		return 0
+	}
+	i := bytes.Index(data, bytes.TrimSpace(quoted.Bytes()))
+	if i < 0 {
+		return 0
+	}
+	return bytes.Count(data[:i], []byte("\n")) + 1
+}
+
+// annotationFile returns a test file's path as GitHub expects it, relative to the working directory
+func annotationFile(path string) string {
+	if filepath.IsAbs(path) {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+				path = rel
+			}
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// writeGitHubReport writes an ::error workflow command for each failing test, pointing at
+// the test's file when it came from one, so failures show inline in the Actions UI
+func writeGitHubReport(w io.Writer, rep *runReport) error {
+	files := make(map[string][]byte)
+	printed := make(map[string]bool)
+	for _, tc := range rep.TestCases {
+		r, ok := rep.Results[tc.resultKey()]
+		if !ok || printed[tc.resultKey()] || r.passed() {
+			continue
+		}
+		printed[tc.resultKey()] = true
+
+		var props []string
+		if tc.sourceFile != "" {
+			props = append(props, "file="+githubPropertyEscaper.Replace(annotationFile(tc.sourceFile)))
+			data, ok := files[tc.sourceFile]
+			if !ok {
+				data, _ = os.ReadFile(tc.sourceFile)
+				files[tc.sourceFile] = data
+			}
+			if line := testLine(data, tc.Description); line > 0 {
+				props = append(props, fmt.Sprintf("line=%d", line))
+			}
+		}
+		props = append(props, "title="+githubPropertyEscaper.Replace("mini_tester: "+r.Description))
+
+		msg := fmt.Sprintf("Command: %s\n%s", tc.Command, firstDifference(r))
+		if d := rep.Differences[tc.resultKey()]; d != "" {
+			diff := strings.Split(plainDiff(d), "\n")
+			if len(diff) > maxAnnotationDiffLines {
+				diff = append(diff[:maxAnnotationDiffLines], fmt.Sprintf("(%d more lines)", len(diff)-maxAnnotationDiffLines))
+			}
+			msg += "\n" + strings.Join(diff, "\n")
+		}
+		if _, err := fmt.Fprintf(w, "::error %s::%s\n", strings.Join(props, ","), githubEscaper.Replace(msg)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// maxSignatureEdit is how much of an inserted or deleted run a group label shows
+const maxSignatureEdit = 30
+
+// diffGroup is a set of failing tests whose differences have the same signature
+type diffGroup struct {
+	Label string
+	Keys  []string
+}
+
+// streamEdits returns the insertions and deletions turning bash's output into minishell's,
+// dropping the text they share so tests differing in the same way get the same edits
+func streamEdits(dmp *diffmatchpatch.DiffMatchPatch, a, b string) []diffmatchpatch.Diff {
+	var diffs []diffmatchpatch.Diff
+	if len(a)+len(b) <= maxCharDiffBytes {
+		diffs = dmp.DiffCleanupSemantic(dmp.DiffMain(a, b, false))
+	} else {
+		charsA, charsB, lines := dmp.DiffLinesToChars(a, b)
+		diffs = dmp.DiffCharsToLines(dmp.DiffMain(charsA, charsB, false), lines)
+	}
+
+	var edits []diffmatchpatch.Diff
+	for _, d := range diffs {
+		if d.Type != diffmatchpatch.DiffEqual {
+			edits = append(edits, d)
+		}
+	}
+	return edits
+}
+
+// describeEdit renders one edit for a group label, shortening long runs of text
+func describeEdit(stream string, d diffmatchpatch.Diff) string {
+	op := "+"
+	if d.Type == diffmatchpatch.DiffDelete {
+		op = "-"
+	}
+	text := []rune(d.Text)
+	if len(text) > maxSignatureEdit {
+		return fmt.Sprintf("%s %s%q...", stream, op, string(text[:maxSignatureEdit]))
+	}
+	return fmt.Sprintf("%s %s%q", stream, op, d.Text)
+}
+
+// diffSignature normalizes how a failing test differs into a hash for clustering and
+// a short label naming the difference
+func diffSignature(dmp *diffmatchpatch.DiffMatchPatch, r TestResult) (string, string) {
+	h := sha256.New()
+	var label []string
+	addEdits := func(stream, a, b string) {
+		for _, d := range streamEdits(dmp, a, b) {
+			fmt.Fprintf(h, "%s\x00%d\x00%s\x00", stream, d.Type, d.Text)
+			label = append(label, describeEdit(stream, d))
+		}
+	}
+	if !r.OutputMatch {
+		addEdits("stdout", r.BashOutput, r.MinishellOutput)
+	}
+	if !r.ErrorMatch {
+		addEdits("stderr", r.BashError, r.MinishellError)
+	}
+	if !r.ReturnCodeMatch {
+		codes := fmt.Sprintf("exit code %d vs %d", r.BashReturnCode, r.MinishellReturnCode)
+		fmt.Fprintf(h, "%s\x00", codes)
+		label = append(label, codes)
+	}
+	if len(label) == 0 {
+		// Other failures carry test-specific text, so they only group when the whole
+		// first difference is the same
+		reason := firstDifference(r)
+		if reason == "" && r.BuildsDiverge {
+			reason = "minishell builds diverge"
+		}
+		fmt.Fprintf(h, "%s\x00", reason)
+		label = append(label, reason)
+	}
+	return hex.EncodeToString(h.Sum(nil)), strings.Join(label, ", ")
+}
+
+// groupDifferences clusters the failing tests by diff signature, largest group first
+// and by description within a group
+func groupDifferences(results map[string]TestResult, differences map[string]string) []diffGroup {
+	dmp := newDiffer()
+	bySignature := make(map[string]*diffGroup)
+	for key := range differences {
+		sig, label := diffSignature(dmp, results[key])
+		g, ok := bySignature[sig]
+		if !ok {
+			g = &diffGroup{Label: label}
+			bySignature[sig] = g
+		}
+		g.Keys = append(g.Keys, key)
+	}
+
+	groups := make([]diffGroup, 0, len(bySignature))
+	for _, g := range bySignature {
+		sort.Slice(g.Keys, func(i, j int) bool {
+			return results[g.Keys[i]].Description < results[g.Keys[j]].Description
+		})
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i].Keys) != len(groups[j].Keys) {
+			return len(groups[i].Keys) > len(groups[j].Keys)
+		}
+		return results[groups[i].Keys[0]].Description < results[groups[j].Keys[0]].Description
+	})
+	return groups
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// historyEntry is the summary of one run kept in -history-dir
+type historyEntry struct {
+	Timestamp   time.Time `json:"timestamp"`
+	TotalTests  int       `json:"total_tests"`
+	PassedTests int       `json:"passed_tests"`
+	PassRatio   float64   `json:"pass_ratio"`
+	// Tests maps each test's description to whether it passed
+	Tests map[string]bool `json:"tests"`
+}
+
+// newHistoryEntry summarizes a run's results as of now. Tests are named by
+// description; a repeated description is followed by the test's result key.
+func newHistoryEntry(results map[string]TestResult) historyEntry {
+	entry := historyEntry{Timestamp: time.Now(), TotalTests: len(results), PassRatio: 1, Tests: make(map[string]bool)}
+	keys := make([]string, 0, len(results))
+	for key := range results {
+		keys = append(keys, key)
+	}
+	// Sorted, so the same test keeps the plain description from run to run
+	sort.Strings(keys)
+	for _, key := range keys {
+		r := results[key]
+		name := r.Description
+		if _, taken := entry.Tests[name]; taken {
+			name = fmt.Sprintf("%s (%s)", r.Description, key)
+		}
+		entry.Tests[name] = r.passed()
+		if r.passed() {
+			entry.PassedTests++
+		}
+	}
+	if entry.TotalTests > 0 {
+		entry.PassRatio = float64(entry.PassedTests) / float64(entry.TotalTests)
+	}
+	return entry
+}
+
+// appendHistory writes a run summary as a new file in dir
+func appendHistory(dir string, entry historyEntry) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating history directory: %v", err)
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error creating history entry: %v", err)
+	}
+	name := fmt.Sprintf("run-%s.json", entry.Timestamp.UTC().Format("20060102T150405.000000000Z"))
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		return fmt.Errorf("error writing history entry: %v", err)
+	}
+	return nil
+}
+
+// loadHistoryDir reads every run summary in dir, oldest first
+func loadHistoryDir(dir string) ([]historyEntry, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "run-*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing history directory: %v", err)
+	}
+
+	entries := make([]historyEntry, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", file, err)
+		}
+		var entry historyEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %v", file, err)
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Timestamp.Before(entries[j].Timestamp) })
+	return entries, nil
+}
+
+// statusChanges lists tests whose pass/fail status differs between two runs
+func statusChanges(prev, last historyEntry) (newlyPassing, newlyFailing []string) {
+	for name, passed := range last.Tests {
+		was, ok := prev.Tests[name]
+		if !ok || was == passed {
+			continue
+		}
+		if passed {
+			newlyPassing = append(newlyPassing, name)
+		} else {
+			newlyFailing = append(newlyFailing, name)
+		}
+	}
+	sort.Strings(newlyPassing)
+	sort.Strings(newlyFailing)
+	return newlyPassing, newlyFailing
+}
+
+// mixedHistory lists tests that have both passed and failed across the runs
+func mixedHistory(entries []historyEntry) []string {
+	passed, failed := make(map[string]bool), make(map[string]bool)
+	for _, entry := range entries {
+		for name, ok := range entry.Tests {
+			if ok {
+				passed[name] = true
+			} else {
+				failed[name] = true
+			}
+		}
+	}
+	var mixed []string
+	for name := range passed {
+		if failed[name] {
+			mixed = append(mixed, name)
+		}
+	}
+	sort.Strings(mixed)
+	return mixed
+}
+
+// runStats implements the stats subcommand
+func runStats(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	historyDir := fs.String("history-dir", "", "Directory of run summaries written with -history-dir")
+	_ = fs.Parse(args)
+
+	if *historyDir == "" {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: -history-dir is required\n")
+		os.Exit(1)
+	}
+	entries, err := loadHistoryDir(*historyDir)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(entries) == 0 {
+		fmt.Printf("No runs recorded in %s\n", *historyDir)
+		return
+	}
+
+	fmt.Printf("Pass ratio over %d run(s):\n", len(entries))
+	fmt.Println(strings.Repeat("=", 50))
+	for _, entry := range entries {
+		fmt.Printf("%s  %4d/%-4d %5.1f%% %s\n", entry.Timestamp.Local().Format("2006-01-02 15:04:05"),
+			entry.PassedTests, entry.TotalTests, 100*entry.PassRatio, strings.Repeat("#", int(entry.PassRatio*20)))
+	}
+
+	if len(entries) >= 2 {
+		newlyPassing, newlyFailing := statusChanges(entries[len(entries)-2], entries[len(entries)-1])
+		fmt.Printf("\nChanged in the latest run:\n")
+		fmt.Println(strings.Repeat("=", 50))
+		for _, name := range newlyFailing {
+			fmt.Printf("NOW FAILING  %s\n", name)
+		}
+		for _, name := range newlyPassing {
+			fmt.Printf("NOW PASSING  %s\n", name)
+		}
+		if len(newlyPassing)+len(newlyFailing) == 0 {
+			fmt.Println("(no changes)")
+		}
+	}
+
+	if mixed := mixedHistory(entries); len(mixed) > 0 {
+		fmt.Printf("\nTests that both passed and failed across runs (%d):\n", len(mixed))
+		fmt.Println(strings.Repeat("=", 50))
+		for _, name := range mixed {
+			fmt.Println(name)
+		}
+	}
+}
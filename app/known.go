@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// loadKnownDiffs reads accepted differences as a JSON object of command to diff,
+// the same shape as the "differences" map in the -output results file
+func loadKnownDiffs(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading known differences file: %v", err)
+	}
+	var known map[string]string
+	if err := json.Unmarshal(data, &known); err != nil {
+		return nil, fmt.Errorf("error parsing known differences file: %v", err)
+	}
+	return known, nil
+}
+
+// applyKnownDiffs accepts failures whose diff exactly matches a known difference,
+// removing them from differences. It returns the commands whose known difference
+// no longer occurs because they now pass, and those still failing with another diff.
+func applyKnownDiffs(results map[string]TestResult, differences, known map[string]string) (stale, changed []string) {
+	for cmd, accepted := range known {
+		result, ran := results[cmd]
+		if !ran {
+			continue
+		}
+		diff, failed := differences[cmd]
+		if !failed {
+			stale = append(stale, cmd)
+			continue
+		}
+		if diff != accepted {
+			changed = append(changed, cmd)
+			continue
+		}
+		result.Accepted = true
+		results[cmd] = result
+		delete(differences, cmd)
+	}
+	sort.Strings(stale)
+	sort.Strings(changed)
+	return stale, changed
+}
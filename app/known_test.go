@@ -0,0 +1,41 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyKnownDiffs(t *testing.T) {
+	results := map[string]TestResult{
+		"accepted": {}, "changed": {}, "fixed": {}, "new failure": {},
+	}
+	differences := map[string]string{
+		"accepted":    "same diff",
+		"changed":     "another diff",
+		"new failure": "diff",
+	}
+	known := map[string]string{
+		"accepted":  "same diff",
+		"changed":   "old diff",
+		"fixed":     "old diff",
+		"never ran": "old diff",
+	}
+
+	stale, changed := applyKnownDiffs(results, differences, known)
+	if want := []string{"fixed"}; !reflect.DeepEqual(stale, want) {
+		t.Errorf("stale = %q, want %q", stale, want)
+	}
+	if want := []string{"changed"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed = %q, want %q", changed, want)
+	}
+	if !results["accepted"].Accepted || results["changed"].Accepted {
+		t.Errorf("accepted = %v, changed accepted = %v; want only the exact match accepted",
+			results["accepted"].Accepted, results["changed"].Accepted)
+	}
+	if _, ok := differences["accepted"]; ok {
+		t.Error("the accepted difference is still reported")
+	}
+	if _, ok := differences["changed"]; !ok {
+		t.Error("the changed difference is no longer reported")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// leftoverProcesses lists processes still in a shell's process group after the
+// shell exited, as "pid command" strings. It relies on /proc and finds nothing
+// where that isn't available.
+func leftoverProcesses(pgid int) []string {
+	stats, _ := filepath.Glob("/proc/[0-9]*/stat")
+	var leftovers []string
+	for _, path := range stats {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		// The command name is parenthesized and may itself contain spaces or parens
+		stat := string(data)
+		open, end := strings.Index(stat, "("), strings.LastIndex(stat, ")")
+		if open < 0 || end < open {
+			continue
+		}
+		// Fields after the name: state, ppid, pgrp, ...
+		fields := strings.Fields(stat[end+1:])
+		if len(fields) < 3 {
+			continue
+		}
+		if pgrp, err := strconv.Atoi(fields[2]); err != nil || pgrp != pgid {
+			continue
+		}
+		leftovers = append(leftovers, fmt.Sprintf("%s %s", strings.TrimSpace(stat[:open]), stat[open+1:end]))
+	}
+	return leftovers
+}
+
+// reapLeftovers reports and then kills whatever is left in a shell's process group
+func reapLeftovers(pgid int) []string {
+	leftovers := leftoverProcesses(pgid)
+	if len(leftovers) > 0 {
+		_ = syscall.Kill(-pgid, syscall.SIGKILL)
+	}
+	return leftovers
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"sync"
+)
+
+// defaultMaxOutputBytes caps how much output a single shell run may produce
+const defaultMaxOutputBytes = 10 << 20
+
+// outputLimit bounds the combined bytes written to several writers and fires onExceed once
+type outputLimit struct {
+	mu        sync.Mutex
+	remaining int64
+	exceeded  bool
+	onExceed  func()
+}
+
+// newOutputLimit allows max bytes in total before discarding output and calling onExceed
+func newOutputLimit(max int64, onExceed func()) *outputLimit {
+	return &outputLimit{remaining: max, onExceed: onExceed}
+}
+
+// writer wraps w so its writes count against the limit
+func (l *outputLimit) writer(w io.Writer) io.Writer {
+	return &limitedWriter{limit: l, w: w}
+}
+
+// truncated reports whether the limit was hit
+func (l *outputLimit) truncated() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.exceeded
+}
+
+// limitedWriter forwards writes until its outputLimit is used up, then discards them
+type limitedWriter struct {
+	limit *outputLimit
+	w     io.Writer
+}
+
+// Write keeps reporting success after the limit so the process is killed rather than blocked
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	l := lw.limit
+	l.mu.Lock()
+	allowed := int64(len(p))
+	if allowed > l.remaining {
+		allowed = l.remaining
+	}
+	l.remaining -= allowed
+	fire := allowed < int64(len(p)) && !l.exceeded
+	if fire {
+		l.exceeded = true
+	}
+	l.mu.Unlock()
+
+	if allowed > 0 {
+		if _, err := lw.w.Write(p[:allowed]); err != nil {
+			return 0, err
+		}
+	}
+	if fire {
+		l.onExceed()
+	}
+	return len(p), nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// schemaVersion is the newest test file format version this tester understands
+const schemaVersion = 1
+
+// checkSchemaVersion rejects test files written for a newer or invalid format version
+func checkSchemaVersion(version int) error {
+	if version < 0 || version > schemaVersion {
+		return fmt.Errorf("unsupported test file version %d (this tester supports up to %d)", version, schemaVersion)
+	}
+	return nil
+}
+
+// stdioPath stands for standard input or output in place of a file path
+const stdioPath = "-"
+
+// loadTestCases loads test cases from a JSON or YAML file, from every .json, .yaml and
+// .yml file in a directory, or from JSON on standard input when path is "-".
+// With continueOnError, files that fail to load are skipped and reported instead of aborting.
+func loadTestCases(path string, continueOnError bool) ([]TestCase, []string, error) {
+	if path == stdioPath {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading stdin: %v", err)
+		}
+		// Expected output files are resolved relative to the working directory
+		testCases, err := parseTestCases(data, ".")
+		return testCases, nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading file: %v", err)
+	}
+	if !info.IsDir() {
+		testCases, err := loadTestFile(path)
+		return testCases, nil, err
+	}
+
+	var files []string
+	for _, pattern := range []string{"*.json", "*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(path, pattern))
+		if err != nil {
+			return nil, nil, fmt.Errorf("error listing directory: %v", err)
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	var testCases []TestCase
+	var skippedFiles []string
+	for _, file := range files {
+		fileCases, err := loadTestFile(file)
+		if err != nil {
+			if !continueOnError {
+				return nil, nil, fmt.Errorf("%s: %v", file, err)
+			}
+			_, _ = fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", file, err)
+			skippedFiles = append(skippedFiles, fmt.Sprintf("%s: %v", file, err))
+			continue
+		}
+		testCases = append(testCases, fileCases...)
+	}
+
+	return testCases, skippedFiles, nil
+}
+
+// testFileFormat guesses a test file's format from its extension
+func testFileFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+	return "json"
+}
+
+// loadTestFile loads test cases from a single JSON or YAML file, in either the flat
+// test_cases form or grouped into named suites
+func loadTestFile(path string) ([]TestCase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %v", err)
+	}
+	if testFileFormat(path) == "yaml" {
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, err
+		}
+	}
+	testCases, err := parseTestCases(data, filepath.Dir(path))
+	for i := range testCases {
+		testCases[i].sourceFile = path
+	}
+	return testCases, err
+}
+
+// parseTestCases decodes test cases and resolves expected output files relative to baseDir
+func parseTestCases(data []byte, baseDir string) ([]TestCase, error) {
+	var testCases TestCases
+	if err := json.Unmarshal(data, &testCases); err != nil {
+		return nil, fmt.Errorf("error parsing JSON: %v", err)
+	}
+	if err := checkSchemaVersion(testCases.Version); err != nil {
+		return nil, err
+	}
+
+	// Flatten named suites, remembering which suite each case came from
+	for _, suite := range testCases.Suites {
+		for _, tc := range suite.Tests {
+			tc.Suite = suite.Name
+			testCases.Tests = append(testCases.Tests, tc)
+		}
+	}
+
+	// Validate comparators, transforms, signals and line expectations, expand command
+	// templates, and resolve expected output files relative to the test file
+	for i, tc := range testCases.Tests {
+		command, err := expandCommand(tc.Command, mergeVars(testCases.Vars, tc.Vars))
+		if err != nil {
+			return nil, fmt.Errorf("test %q: %v", tc.Description, err)
+		}
+		testCases.Tests[i].Command = command
+		if err := validateComparator(tc.Comparator); err != nil {
+			return nil, fmt.Errorf("test %q: %v", tc.Description, err)
+		}
+		if err := validateErrorComparator(tc.ErrorComparator); err != nil {
+			return nil, fmt.Errorf("test %q: %v", tc.Description, err)
+		}
+		if err := validateTransforms(tc.TransformOutput); err != nil {
+			return nil, fmt.Errorf("test %q: %v", tc.Description, err)
+		}
+		if err := validateSignalName(tc.ExpectedSignal); err != nil {
+			return nil, fmt.Errorf("test %q: %v", tc.Description, err)
+		}
+		if err := validateExpectedLines(tc.ExpectedLines); err != nil {
+			return nil, fmt.Errorf("test %q: %v", tc.Description, err)
+		}
+		if tc.Points < 0 {
+			return nil, fmt.Errorf("test %q: points must not be negative, got %d", tc.Description, tc.Points)
+		}
+		if tc.ExpectNonEmptyError && tc.ExpectEmptyError {
+			return nil, fmt.Errorf("test %q: expect_non_empty_error and expect_empty_error contradict each other", tc.Description)
+		}
+		if err := validateAssertions(tc.Assertions); err != nil {
+			return nil, fmt.Errorf("test %q: %v", tc.Description, err)
+		}
+		if err := validateProbes(tc.AssertAfter, tc.AssertExpected); err != nil {
+			return nil, fmt.Errorf("test %q: %v", tc.Description, err)
+		}
+		for _, pattern := range tc.IgnoreLinesMatching {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("test %q: invalid ignore_lines_matching pattern: %v", tc.Description, err)
+			}
+		}
+		if tc.ExpectedOutputFile != "" && !filepath.IsAbs(tc.ExpectedOutputFile) {
+			testCases.Tests[i].ExpectedOutputFile = filepath.Join(baseDir, tc.ExpectedOutputFile)
+		}
+	}
+
+	return testCases.Tests, nil
+}
+
+// assignIDs gives each test the key its result is stored under: its command, with
+// " #2", " #3", ... appended to later tests repeating it, skipping any suffixed key
+// that is itself another test's command. It returns each repeated command with the
+// number of tests using it, in order of first appearance, and fails if two tests
+// would still share a key.
+func assignIDs(testCases []TestCase) (duplicates []string, counts map[string]int, err error) {
+	taken := make(map[string]bool)
+	for _, tc := range testCases {
+		taken[tc.Command] = true
+	}
+
+	counts = make(map[string]int)
+	for i, tc := range testCases {
+		counts[tc.Command]++
+		n := counts[tc.Command]
+		if n == 1 {
+			testCases[i].id = tc.Command
+			continue
+		}
+		if n == 2 {
+			duplicates = append(duplicates, tc.Command)
+		}
+		id := fmt.Sprintf("%s #%d", tc.Command, n)
+		for taken[id] {
+			n++
+			id = fmt.Sprintf("%s #%d", tc.Command, n)
+		}
+		taken[id] = true
+		testCases[i].id = id
+	}
+
+	// A shared key would let one test's result silently replace another's
+	owners := make(map[string]string)
+	for _, tc := range testCases {
+		if other, ok := owners[tc.id]; ok {
+			return nil, nil, fmt.Errorf("tests %q and %q have the same result key %q", other, tc.Description, tc.id)
+		}
+		owners[tc.id] = tc.Description
+	}
+	return duplicates, counts, nil
+}
+
+// secondID returns the result key of the second test running command
+func secondID(testCases []TestCase, command string) string {
+	seen := false
+	for _, tc := range testCases {
+		if tc.Command != command {
+			continue
+		}
+		if seen {
+			return tc.id
+		}
+		seen = true
+	}
+	return command
+}
+
+// resultKey returns the key of the test's result, falling back to its command
+// for tests that were never given an ID
+func (tc TestCase) resultKey() string {
+	if tc.id == "" {
+		return tc.Command
+	}
+	return tc.id
+}
+
+// hasExpectations reports whether a test asserts anything beyond matching bash
+func hasExpectations(tc TestCase) bool {
+	return tc.ExpectedOutput != "" || tc.ExpectedOutputFile != "" || tc.ExpectedFromCommand != "" || tc.ExpectEmptyOutput ||
+		tc.ExpectedError != "" || tc.ExpectEmptyError || tc.ExpectedCode != 0 || tc.ExpectedSignal != "" ||
+		len(tc.ExpectedLines) > 0 || tc.ExpectedCombined != "" || len(tc.Assertions) > 0 || len(tc.AssertExpected) > 0
+}
+
+// checkExpectations returns an error naming every test that has no expectations
+func checkExpectations(testCases []TestCase) error {
+	var missing []string
+	for _, tc := range testCases {
+		if !hasExpectations(tc) {
+			missing = append(missing, fmt.Sprintf("%q", tc.Description))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%d test(s) have no expectations: %s", len(missing), strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// unsafeFileChars matches runs of characters not kept in log file names
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// maxLogNameLen bounds the description part of a log file name
+const maxLogNameLen = 60
+
+// logFileName builds a file name from a test's index and sanitized description
+func logFileName(index int, description string) string {
+	name := strings.Trim(unsafeFileChars.ReplaceAllString(description, "_"), "_.")
+	if len(name) > maxLogNameLen {
+		name = name[:maxLogNameLen]
+	}
+	if name == "" {
+		name = "test"
+	}
+	return fmt.Sprintf("%03d_%s.log", index, name)
+}
+
+// formatTestLog renders everything known about one test run as plain text
+func formatTestLog(command string, result TestResult, diff string) string {
+	var sb strings.Builder
+	status := "PASS"
+	if !result.passed() {
+		status = "FAIL"
+	}
+	fmt.Fprintf(&sb, "Test: %s\n", result.Description)
+	if result.Suite != "" {
+		fmt.Fprintf(&sb, "Suite: %s\n", result.Suite)
+	}
+	fmt.Fprintf(&sb, "Command: %s\n", command)
+	fmt.Fprintf(&sb, "Status: %s\n", status)
+	fmt.Fprintf(&sb, "\nBash return code: %d\n", result.BashReturnCode)
+	fmt.Fprintf(&sb, "Minishell return code: %d\n", result.MinishellReturnCode)
+	fmt.Fprintf(&sb, "\n--- bash stdout ---\n%s\n", result.BashOutput)
+	fmt.Fprintf(&sb, "\n--- minishell stdout ---\n%s\n", result.MinishellOutput)
+	fmt.Fprintf(&sb, "\n--- bash stderr ---\n%s\n", result.BashError)
+	fmt.Fprintf(&sb, "\n--- minishell stderr ---\n%s\n", result.MinishellError)
+	if diff != "" {
+		fmt.Fprintf(&sb, "\n--- diff ---\n%s\n", diff)
+	}
+	if result.MinishellStrace != "" {
+		fmt.Fprintf(&sb, "\n--- minishell strace ---\n%s\n", result.MinishellStrace)
+	}
+	return sb.String()
+}
+
+// writeTestLogs writes one log file per test case into dir, creating it if needed
+func writeTestLogs(dir string, testCases []TestCase, results map[string]TestResult, differences map[string]string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating log directory: %v", err)
+	}
+	for i, tc := range testCases {
+		result, ok := results[tc.resultKey()]
+		if !ok {
+			continue
+		}
+		path := filepath.Join(dir, logFileName(i+1, tc.Description))
+		if err := os.WriteFile(path, []byte(formatTestLog(tc.Command, result, differences[tc.resultKey()])), 0644); err != nil {
+			return fmt.Errorf("error writing log file: %v", err)
+		}
+	}
+	return nil
+}
@@ -2,15 +2,20 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
 	"strings"
-
-	"github.com/sergi/go-diff/diffmatchpatch"
+	"syscall"
+	"time"
 )
 
 // TestCase represents a single shell command test case
@@ -20,16 +25,155 @@ type TestCase struct {
 	ExpectedOutput string `json:"expected_output,omitempty"`
 	ExpectedError  string `json:"expected_error,omitempty"`
 	ExpectedCode   int    `json:"expected_code,omitempty"`
+	// MinDurationMs asserts minishell took at least this long, to catch commands
+	// that should block but return immediately
+	MinDurationMs int `json:"min_duration_ms,omitempty"`
+	// TimeoutMs overrides the global -timeout for this case; 0 means "use global default"
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+	// ShellVars are exported inside the shell session before the command runs,
+	// unlike the process environment the shell inherits when it starts
+	ShellVars map[string]string `json:"shell_vars,omitempty"`
+	// Vars fill {{.Vars.name}} placeholders in the command, overriding the file's vars
+	Vars map[string]string `json:"vars,omitempty"`
+	// ExpectedOutputFile holds the expected output, resolved relative to the test file
+	ExpectedOutputFile string `json:"expected_output_file,omitempty"`
+	// ExpectedFromCommand is run by bash in the test's directory to produce the expected
+	// output when the test runs, for expectations such as the current year
+	ExpectedFromCommand string `json:"expected_from_command,omitempty"`
+	// ExpectEmptyOutput and ExpectEmptyError assert the stream is exactly empty,
+	// which an empty expected_output/expected_error cannot express
+	ExpectEmptyOutput bool `json:"expect_empty_output,omitempty"`
+	ExpectEmptyError  bool `json:"expect_empty_error,omitempty"`
+	// ExpectNonEmptyError compares stderr only by whether both shells wrote any,
+	// for errors whose wording is allowed to differ
+	ExpectNonEmptyError bool `json:"expect_non_empty_error,omitempty"`
+	// EOF closes stdin after the command without sending exit, like Ctrl-D at the prompt
+	EOF bool `json:"eof,omitempty"`
+	// SendExit overrides the -no-exit default for whether exit is sent after the command;
+	// nil means "use global default"
+	SendExit *bool `json:"send_exit,omitempty"`
+	// NondeterministicRuns runs both shells this many times; minishell passes if each
+	// of its outputs matches one that bash produced in any run
+	NondeterministicRuns int `json:"nondeterministic_runs,omitempty"`
+	// ExpectedCombined is compared with minishell's stdout and stderr interleaved in
+	// arrival order, for when only the union of both streams matters
+	ExpectedCombined string `json:"expected_combined,omitempty"`
+	// CheckInterleaving reruns both shells with stdout and stderr on a single pipe and
+	// requires the merged streams to match, so the order of writes to the two is checked
+	CheckInterleaving bool `json:"check_interleaving,omitempty"`
+	// TtyRows and TtyCols fix the terminal size commands see through LINES and COLUMNS;
+	// shells run on pipes, so there is no terminal for TIOCGWINSZ to query
+	TtyRows int `json:"tty_rows,omitempty"`
+	TtyCols int `json:"tty_cols,omitempty"`
+	// ExpectedLines asserts individual 1-based lines of minishell's stdout; the
+	// remaining lines are still compared with bash
+	ExpectedLines map[int]string `json:"expected_lines,omitempty"`
+	// ExpectedSignal asserts minishell, or the command it ran, was killed by this signal (e.g. "SEGV")
+	ExpectedSignal string `json:"expected_signal,omitempty"`
+	// Comparator selects how stdout is compared with bash: "exact" (default), "numeric-tolerance"
+	// or "env-set"
+	Comparator string `json:"comparator,omitempty"`
+	// IgnoreKeys names variables the env-set comparator leaves out
+	IgnoreKeys []string `json:"ignore_keys,omitempty"`
+	// PostProcess is a bash command each shell's stdout is piped through, e.g. "sort";
+	// the results are compared while the raw outputs are kept for the diff
+	PostProcess string `json:"post_process,omitempty"`
+	// ExpectedTailLines compares only the last this-many lines of stdout; 0 compares all of it
+	ExpectedTailLines int `json:"expected_tail_lines,omitempty"`
+	// TransformOutput is a comma-separated chain of built-in transforms, such as
+	// "strip-ansi,sort-lines", applied to both stdouts before comparison
+	TransformOutput string `json:"transform_output,omitempty"`
+	// ErrorComparator selects how stderr is compared: "exact" (default) or "command-not-found",
+	// which ignores the program name prefix of command not found messages
+	ErrorComparator string `json:"error_comparator,omitempty"`
+	// IgnoreLinesMatching drops stdout lines matching any of these regexes before comparison
+	IgnoreLinesMatching []string `json:"ignore_lines_matching,omitempty"`
+	// AbsTolerance and RelTolerance bound numeric differences for the numeric-tolerance comparator
+	AbsTolerance float64 `json:"abs_tolerance,omitempty"`
+	RelTolerance float64 `json:"rel_tolerance,omitempty"`
+	// TrailingNewlineSignificant fails the test unless minishell's stdout ends with
+	// as many newlines as bash's, which trimming would otherwise hide
+	TrailingNewlineSignificant bool `json:"trailing_newline_significant,omitempty"`
+	// Assertions are further checks on minishell's stdout, stderr and exit code, all of which must hold
+	Assertions []Assertion `json:"assertions,omitempty"`
+	// AssertAfter are probe commands, such as "echo $FOO", run once the command is done
+	// to check side effects; AssertExpected holds each one's expected output, and
+	// without it the probes must print what they print in bash
+	AssertAfter    []string `json:"assert_after,omitempty"`
+	AssertExpected []string `json:"assert_expected,omitempty"`
+	// ExpectedPwd asserts the directory pwd prints once the command has run, such as
+	// "{{.TmpDir}}/sub"; bash must end up in the same directory
+	ExpectedPwd string `json:"expected_pwd,omitempty"`
+	// RequiresCommands are utilities the test uses, such as "bc"; it is skipped if any isn't in PATH
+	RequiresCommands []string `json:"requires_commands,omitempty"`
+	// Tags label the test for selection with -tags-any, -tags-all and -exclude-tags
+	Tags []string `json:"tags,omitempty"`
+	// Priority orders the run: higher priorities run first, equal ones in file order
+	Priority int `json:"priority,omitempty"`
+	// Points is what the test adds to the score when it passes; 0 means 1 point
+	Points int `json:"points,omitempty"`
+	// Suite is the name of the suite the case was grouped under, if any
+	Suite string `json:"suite,omitempty"`
+
+	// tmpDir is the per-test working directory, emptied before each shell run
+	tmpDir string
+	// mergeStreams gives the shell one pipe for stdout and stderr, captured as stdout
+	mergeStreams bool
+	// id is the key the test's result is stored under, unique within a run (see assignIDs)
+	id string
+	// index is the test's 1-based position among all loaded tests, as selected by -range
+	index int
+	// sourceFile is the file the test was loaded from, if any
+	sourceFile string
 }
 
 // TestCases represents the JSON structure for test cases
 type TestCases struct {
+	// Version is the file format version; files without one are treated as version 1
+	Version int         `json:"version,omitempty"`
+	Tests   []TestCase  `json:"test_cases"`
+	Suites  []TestSuite `json:"suites,omitempty"`
+	// Vars are defaults for every test's {{.Vars.name}} placeholders
+	Vars map[string]string `json:"vars,omitempty"`
+}
+
+// TestSuite is a named group of test cases reported together
+type TestSuite struct {
+	Name  string     `json:"name"`
 	Tests []TestCase `json:"test_cases"`
 }
 
+// Summary holds aggregate statistics for a test run
+type Summary struct {
+	TotalTests   int   `json:"total_tests"`
+	PassedTests  int   `json:"passed_tests"`
+	FailedTests  int   `json:"failed_tests"`
+	SkippedTests int   `json:"skipped_tests"`
+	DurationMs   int64 `json:"duration_ms"`
+	// FlakyTests and ConsistentlyFailingTests classify failures when -retries is set
+	FlakyTests               int `json:"flaky_tests,omitempty"`
+	ConsistentlyFailingTests int `json:"consistently_failing_tests,omitempty"`
+	// Score and MaxScore sum the points of passing and of all tests
+	Score    int `json:"score"`
+	MaxScore int `json:"max_score"`
+
+	Suites []SuiteSummary `json:"suites,omitempty"`
+}
+
+// SuiteSummary holds pass counts for one named suite
+type SuiteSummary struct {
+	Name        string `json:"name"`
+	TotalTests  int    `json:"total_tests"`
+	PassedTests int    `json:"passed_tests"`
+}
+
 // TestResult stores the results of a single test
 type TestResult struct {
 	Description         string `json:"description"`
+	Command             string `json:"command"`
+	Index               int    `json:"index,omitempty"`
+	Suite               string `json:"suite,omitempty"`
+	Points              int    `json:"points,omitempty"`
 	BashOutput          string `json:"bash_output"`
 	MinishellOutput     string `json:"minishell_output"`
 	BashError           string `json:"bash_error"`
@@ -42,49 +186,476 @@ type TestResult struct {
 	ExpectedOutputMatch bool   `json:"expected_output_match"`
 	ExpectedErrorMatch  bool   `json:"expected_error_match"`
 	ExpectedCodeMatch   bool   `json:"expected_code_match"`
+	ExpectedLinesMatch  bool   `json:"expected_lines_match"`
+	// ExpectNonEmptyError says ErrorMatch only required both shells to write to stderr
+	ExpectNonEmptyError bool `json:"expect_non_empty_error,omitempty"`
+	// PostProcessed outputs are only set for tests with post_process
+	BashPostProcessed      string `json:"bash_post_processed,omitempty"`
+	MinishellPostProcessed string `json:"minishell_post_processed,omitempty"`
+	// MinishellCombined is only captured for tests that set expected_combined
+	MinishellCombined     string `json:"minishell_combined,omitempty"`
+	ExpectedCombinedMatch bool   `json:"expected_combined_match"`
+	// Probe outputs are what each assert_after probe printed; FailedProbes describes the mismatches
+	BashProbeOutputs      []string `json:"bash_probe_outputs,omitempty"`
+	MinishellProbeOutputs []string `json:"minishell_probe_outputs,omitempty"`
+	FailedProbes          []string `json:"failed_probes,omitempty"`
+	// FailedAssertions describes each of the test's assertions that minishell didn't meet
+	FailedAssertions []string `json:"failed_assertions,omitempty"`
+	// Merged fields are each shell's stdout and stderr from a single pipe, for tests with check_interleaving
+	BashMerged        string `json:"bash_merged,omitempty"`
+	MinishellMerged   string `json:"minishell_merged,omitempty"`
+	InterleavingMatch bool   `json:"interleaving_match"`
+	// Pwd fields are the directories each shell ended in, for tests with expected_pwd
+	BashPwd      string `json:"bash_pwd,omitempty"`
+	MinishellPwd string `json:"minishell_pwd,omitempty"`
+	ExpectedPwd  string `json:"expected_pwd,omitempty"`
+	PwdMatch     bool   `json:"pwd_match"`
+	// PeakRSSKB fields are each shell's peak resident set size in KiB, recorded with -peak-memory
+	BashPeakRSSKB      int64 `json:"bash_peak_rss_kb,omitempty"`
+	MinishellPeakRSSKB int64 `json:"minishell_peak_rss_kb,omitempty"`
+	// MinishellDurationMs is how long minishell ran; MinDurationMet checks it against min_duration_ms
+	MinishellDurationMs int64 `json:"minishell_duration_ms"`
+	MinDurationMet      bool  `json:"min_duration_met"`
+	// Signals name what terminated each shell or its command, empty if it exited normally
+	BashSignal      string `json:"bash_signal,omitempty"`
+	MinishellSignal string `json:"minishell_signal,omitempty"`
+	// MinishellCrash names the crash signal, such as "SEGV", that killed minishell itself
+	// when bash survived the same input
+	MinishellCrash      string `json:"minishell_crash,omitempty"`
+	ExpectedSignalMatch bool   `json:"expected_signal_match"`
+	BashTimedOut        bool   `json:"bash_timed_out"`
+	MinishellTimedOut   bool   `json:"minishell_timed_out"`
+	// Truncated fields mark a shell killed for producing more than -max-output-bytes
+	BashOutputTruncated      bool `json:"bash_output_truncated"`
+	MinishellOutputTruncated bool `json:"minishell_output_truncated"`
+	// Final newline fields are only compared when -check-final-newline is set
+	BashFinalNewline      bool `json:"bash_final_newline"`
+	MinishellFinalNewline bool `json:"minishell_final_newline"`
+	FinalNewlineMatch     bool `json:"final_newline_match"`
+	// TrailingNewlines count the newlines ending each stdout, for tests with trailing_newline_significant
+	BashTrailingNewlines      int `json:"bash_trailing_newlines,omitempty"`
+	MinishellTrailingNewlines int `json:"minishell_trailing_newlines,omitempty"`
+	// ExpectedOutput and ExpectedError are what minishell was checked against, if anything
+	ExpectedOutput   string         `json:"expected_output,omitempty"`
+	ExpectedError    string         `json:"expected_error,omitempty"`
+	ExpectedLines    map[int]string `json:"expected_lines,omitempty"`
+	ExpectedCombined string         `json:"expected_combined,omitempty"`
+	// LineMismatches are the expected_lines minishell missed, checked after post_process and tail
+	LineMismatches []LineMismatch `json:"line_mismatches,omitempty"`
+	// Timelines are only recorded when -timestamps is set
+	BashTimeline       []TimedLine `json:"bash_timeline,omitempty"`
+	MinishellTimeline  []TimedLine `json:"minishell_timeline,omitempty"`
+	TimelineDivergence int         `json:"timeline_divergence"`
+	// BashOutputVariants lists every distinct output bash produced for nondeterministic tests
+	BashOutputVariants []string `json:"bash_output_variants,omitempty"`
+	// VariableDifferences lists the variables that differ for a failing env-set comparison
+	VariableDifferences []string `json:"variable_differences,omitempty"`
+	// Leftover processes are only recorded when -check-leftover-processes is set
+	BashLeftoverProcesses      []string `json:"bash_leftover_processes,omitempty"`
+	MinishellLeftoverProcesses []string `json:"minishell_leftover_processes,omitempty"`
+	// Minishell2 fields are only set when a second build is compared with -minishell2
+	Minishell2Output     string `json:"minishell2_output,omitempty"`
+	Minishell2Error      string `json:"minishell2_error,omitempty"`
+	Minishell2ReturnCode int    `json:"minishell2_return_code,omitempty"`
+	Minishell2TimedOut   bool   `json:"minishell2_timed_out,omitempty"`
+	// Minishell2Match reports the second build behaved like bash; BuildsDiverge that it behaved unlike minishell
+	Minishell2Match bool `json:"minishell2_match,omitempty"`
+	BuildsDiverge   bool `json:"builds_diverge,omitempty"`
+	// Attempts holds every run of a failing test retried with -retries, in order;
+	// Flaky marks a test that passed on some attempts and failed on others
+	Attempts []TestResult `json:"attempts,omitempty"`
+	Flaky    bool         `json:"flaky,omitempty"`
+	// MinishellStrace is minishell's process-management syscall trace, kept for failed tests under -strace
+	MinishellStrace string `json:"minishell_strace,omitempty"`
+	// Cached marks results reused from an earlier run of the same shell binaries
+	Cached bool `json:"cached,omitempty"`
+	// Accepted marks a failure whose diff exactly matches one in -known-diffs
+	Accepted bool `json:"accepted,omitempty"`
+	// OutputDropped marks a passing result whose captured output was discarded with -drop-passing-output
+	OutputDropped bool `json:"output_dropped,omitempty"`
+}
+
+// passed reports whether minishell behaved like bash and met the test's expectations
+func (r TestResult) passed() bool {
+	if r.Accepted {
+		return true
+	}
+	return !r.Flaky && r.OutputMatch && r.ErrorMatch && r.ReturnCodeMatch && r.FinalNewlineMatch && !r.MinishellTimedOut && !r.MinishellOutputTruncated &&
+		r.ExpectedOutputMatch && r.ExpectedErrorMatch && r.ExpectedCodeMatch && r.ExpectedSignalMatch && r.ExpectedLinesMatch &&
+		r.ExpectedCombinedMatch && r.MinDurationMet && r.PwdMatch && r.InterleavingMatch && len(r.FailedAssertions) == 0 && len(r.FailedProbes) == 0
+}
+
+// dropOutputs discards a result's captured output and expectations, keeping its outcome
+func (r *TestResult) dropOutputs() {
+	r.BashOutput, r.MinishellOutput, r.BashError, r.MinishellError = "", "", "", ""
+	r.BashPostProcessed, r.MinishellPostProcessed, r.MinishellCombined = "", "", ""
+	r.Minishell2Output, r.Minishell2Error = "", ""
+	r.ExpectedOutput, r.ExpectedError, r.ExpectedCombined = "", "", ""
+	r.BashTimeline, r.MinishellTimeline, r.BashOutputVariants = nil, nil, nil
+	r.OutputDropped = true
 }
 
 // ShellTester handles shell command testing
 type ShellTester struct {
 	bashPath      string
 	minishellPath string
+	// helperBashPath is the bash that runs post_process and expected_from_command, which
+	// stays the real one when -reference-shell takes bashPath's place
+	helperBashPath string
+	// minishell2Path is a second minishell build compared alongside the first, if set
+	minishell2Path string
+	timeout        time.Duration
+	// checkFinalNewline compares whether each shell's stdout ends with a newline
+	checkFinalNewline bool
+	// ignoreStderrUnlessExpected only compares stderr for tests with an expected_error
+	ignoreStderrUnlessExpected bool
+	// exitCodeMap maps exit codes to the ones they are compared as, with -normalize-exit-codes
+	exitCodeMap map[int]int
+	// normalizeErrorPrefix replaces the shell name starting each stderr line with errorPrefixToken
+	normalizeErrorPrefix bool
+	// maxOutputBytes kills a shell once its combined output exceeds this many bytes; 0 disables the cap
+	maxOutputBytes int64
+	// ansiDiff diffs visible text and reports styling differences separately
+	ansiDiff bool
+	// envIgnore names variables dropped from both outputs of env-printing commands
+	envIgnore map[string]bool
+	// timestamps records when each output line arrives to compare ordering over time
+	timestamps bool
+	// checkLeftovers reports processes left in a shell's process group after it exits
+	checkLeftovers bool
+	// bashRCFile is sourced by bash, and only bash, before each test via BASH_ENV
+	bashRCFile string
+	// retries reruns a failing test up to this many more times to tell flaky from consistent failures
+	retries int
+	// stracePath runs minishell under strace to record process-management syscalls; "" disables it
+	stracePath string
+	// cache reuses earlier runs of unchanged shell binaries; nil disables it
+	cache *resultCache
+	// bashRuns holds this run's bash results by invocation, and bashReuses counts
+	// the tests that were given one instead of running bash again
+	bashRuns   map[string]bashRun
+	bashReuses int
+	// ctx cancels every in-flight shell when the run is interrupted; nil means never
+	ctx context.Context
+	// noExit stops exit being sent after the command unless a test sets send_exit
+	noExit bool
+	// bailOnCrash stops the run at the first test where minishell itself crashes
+	bailOnCrash bool
+	// crashKey is the result key of the test that stopped the run under bailOnCrash
+	crashKey string
+	// dropPassingOutput discards the captured output of passing tests as soon as they finish
+	dropPassingOutput bool
+	// peakMemory records each shell's peak resident set size in the results
+	peakMemory bool
+	// forceLocale is set as LC_ALL for both shells, so formatting doesn't depend on the machine
+	forceLocale string
+	// dockerImage runs the reference bash inside this Docker image instead of locally
+	dockerImage string
+	// dashC passes each test to the shells as "-c script" instead of on stdin
+	dashC bool
+}
+
+// commandResult holds the captured outcome of a single shell invocation
+type commandResult struct {
+	stdout   string
+	stderr   string
+	exitCode int
+	timedOut bool
+	// finalNewline reports whether the untrimmed stdout ended with a newline
+	finalNewline bool
+	// trailingNewlines counts the newlines the untrimmed stdout ended with
+	trailingNewlines int
+	timeline         []TimedLine
+	// truncated reports the shell was killed for exceeding -max-output-bytes
+	truncated bool
+	// signal names the signal that terminated the shell or its command, if any
+	signal string
+	// crash names the crash signal that killed the shell itself, if any
+	crash string
+	// duration is how long the shell ran, from start until it exited
+	duration time.Duration
+	// peakRSS is the shell's peak resident set size in KiB
+	peakRSS int64
+	// leftovers lists processes the shell left running, when checked
+	leftovers []string
+	// combined is stdout and stderr interleaved, captured for expected_combined
+	combined string
+	// strace is minishell's syscall trace, when run with -strace
+	strace string
+}
+
+// resolveShellPath turns a shell path into an absolute one. A bare name such as
+// "minishell" is looked up in PATH and then in the current directory, the way
+// users expect even though exec would only search PATH.
+func resolveShellPath(path, name string) (string, error) {
+	if !strings.ContainsRune(path, filepath.Separator) {
+		if found, err := exec.LookPath(path); err == nil {
+			path = found
+		} else if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("%s executable %q not found in PATH or the current directory", name, path)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("%s executable not found at %s", name, path)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s executable %s is a directory", name, path)
+	}
+	if info.Mode()&0111 == 0 {
+		return "", fmt.Errorf("%s executable %s is not executable (try chmod +x)", name, path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("error resolving %s path: %v", name, err)
+	}
+	return abs, nil
 }
 
 // NewShellTester creates a new ShellTester instance
 func NewShellTester(bashPath, minishellPath string) (*ShellTester, error) {
-	if _, err := os.Stat(bashPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("bash executable not found at %s", bashPath)
+	// Resolve relative paths now, since tests run the shells from their own directories
+	bashPath, err := resolveShellPath(bashPath, "bash")
+	if err != nil {
+		return nil, err
 	}
-	if _, err := os.Stat(minishellPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("minishell executable not found at %s", minishellPath)
+	minishellPath, err = resolveShellPath(minishellPath, "minishell")
+	if err != nil {
+		return nil, err
 	}
-	return &ShellTester{bashPath: bashPath, minishellPath: minishellPath}, nil
+	return &ShellTester{
+		bashPath:       bashPath,
+		minishellPath:  minishellPath,
+		helperBashPath: bashPath,
+		timeout:        defaultTimeout,
+		maxOutputBytes: defaultMaxOutputBytes,
+	}, nil
 }
 
-// runCommand executes a command in the specified shell
-func (st *ShellTester) runCommand(shellPath, command string) (string, string, int) {
-	cmd := exec.Command(shellPath)
+// defaultTimeout is the per-test timeout used when neither -timeout nor timeout_ms is set
+const defaultTimeout = 10 * time.Second
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+// timeoutFor returns the timeout that applies to a test case
+func (st *ShellTester) timeoutFor(tc TestCase) time.Duration {
+	if tc.TimeoutMs > 0 {
+		return time.Duration(tc.TimeoutMs) * time.Millisecond
+	}
+	return st.timeout
+}
 
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return "", err.Error(), 1
+// sendsExit reports whether exit is written after a test's command
+func (st *ShellTester) sendsExit(tc TestCase) bool {
+	if tc.EOF {
+		return false
+	}
+	if tc.SendExit != nil {
+		return *tc.SendExit
+	}
+	// The command's own exit decides the exit code, so don't queue another behind it
+	if isExitCommand(tc.Command) {
+		return false
+	}
+	return !st.noExit
+}
+
+// isExitCommand reports whether a command is a bare call to the exit builtin, like "exit 42"
+func isExitCommand(command string) bool {
+	fields := strings.Fields(command)
+	return len(fields) > 0 && fields[0] == "exit" && !strings.ContainsAny(command, ";|&")
+}
+
+// shellInput builds the script fed to a shell's stdin for a test case
+func shellInput(tc TestCase, sendExit bool) string {
+	var sb strings.Builder
+
+	names := make([]string, 0, len(tc.ShellVars))
+	for name := range tc.ShellVars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&sb, "export %s=%s\n", name, shellQuote(tc.ShellVars[name]))
 	}
 
-	if err := cmd.Start(); err != nil {
-		return "", err.Error(), 1
+	sb.WriteString(tc.Command + "\n")
+	sb.WriteString(trailerInput(tc))
+	if sendExit {
+		sb.WriteString("exit\n")
 	}
+	return sb.String()
+}
 
-	_, err = stdin.Write([]byte(command + "\nexit\n"))
-	if err != nil {
-		return "", err.Error(), 1
+// shellQuote wraps a value in single quotes so the shell takes it literally
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// startRetries is how many times a shell is restarted after a transient fork failure
+const startRetries = 3
+
+// startBackoff is the delay before the first restart; it doubles on each attempt
+const startBackoff = 50 * time.Millisecond
+
+// isTransientStartError reports whether a failed shell start is worth retrying
+func isTransientStartError(err error) bool {
+	return errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.ENOMEM)
+}
+
+// newShellCmd prepares a shell process that is killed along with its children on ctx cancellation
+func newShellCmd(ctx context.Context, shellPath string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, shellPath, args...)
+	// Run the shell in its own process group so a timeout also kills its children
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+	return cmd
+}
+
+// shellEnv returns the environment a shell starts with for a test, or nil to
+// inherit the tester's environment unchanged
+func (st *ShellTester) shellEnv(shellPath string, tc TestCase) []string {
+	extra := st.testEnv(shellPath, tc)
+	if len(extra) == 0 {
+		return nil
+	}
+	return append(os.Environ(), extra...)
+}
+
+// testEnv returns the variables a test sets on top of the inherited environment
+func (st *ShellTester) testEnv(shellPath string, tc TestCase) []string {
+	var extra []string
+	if tc.tmpDir != "" {
+		extra = append(extra, tmpDirEnv+"="+tc.tmpDir)
 	}
-	_ = stdin.Close()
+	if shellPath == st.bashPath && st.bashRCFile != "" {
+		extra = append(extra, "BASH_ENV="+st.bashRCFile)
+	}
+	if st.forceLocale != "" {
+		extra = append(extra, "LC_ALL="+st.forceLocale)
+	}
+	if tc.TtyRows > 0 {
+		extra = append(extra, fmt.Sprintf("LINES=%d", tc.TtyRows))
+	}
+	if tc.TtyCols > 0 {
+		extra = append(extra, fmt.Sprintf("COLUMNS=%d", tc.TtyCols))
+	}
+	return extra
+}
+
+// runCommand executes a test case's command in the specified shell
+func (st *ShellTester) runCommand(shellPath string, tc TestCase) commandResult {
+	parent := context.Background()
+	if st.ctx != nil {
+		parent = st.ctx
+	}
+	ctx, cancel := context.WithTimeout(parent, st.timeoutFor(tc))
+	defer cancel()
+
+	var (
+		cmd            *exec.Cmd
+		stdin          io.WriteCloser
+		stdout, stderr bytes.Buffer
+		err            error
+
+		lines                *timeline
+		outWriter, errWriter *timelineWriter
+		limit                *outputLimit
+		combined             *combinedBuffer
+	)
+	if tc.tmpDir != "" {
+		clearDir(tc.tmpDir)
+	}
+	traceFile := ""
+	if st.stracePath != "" && shellPath == st.minishellPath {
+		if traceFile, err = straceFile(); err != nil {
+			return commandResult{stderr: err.Error(), exitCode: 1}
+		}
+		defer os.Remove(traceFile)
+	}
+	// In -c mode the script is an argument and stdin is left empty
+	input := shellInput(tc, st.sendsExit(tc))
+	var args []string
+	if st.dashC {
+		args = []string{"-c", strings.TrimSuffix(shellInput(tc, false), "\n")}
+		input = ""
+	}
+	// With -docker-bash the reference bash runs in a container; only docker sees the host environment
+	name, env := shellPath, st.shellEnv(shellPath, tc)
+	if shellPath == st.bashPath && st.dockerImage != "" {
+		name, env = "docker", nil
+		args = dockerBashArgs(st.dockerImage, tc.tmpDir, st.bashRCFile, st.testEnv(shellPath, tc), args)
+	}
+	for attempt := 0; ; attempt++ {
+		cmd = newShellCmd(ctx, name, args...)
+		if traceFile != "" {
+			wrapStrace(cmd, st.stracePath, traceFile)
+		}
+		cmd.Dir = tc.tmpDir
+		cmd.Env = env
+		stdout.Reset()
+		stderr.Reset()
+		var outW, errW io.Writer = &stdout, &stderr
+		if tc.ExpectedCombined != "" {
+			combined = &combinedBuffer{}
+			outW, errW = combined.tee(outW), combined.tee(errW)
+		}
+		if st.timestamps {
+			lines = newTimeline()
+			outWriter, errWriter = lines.writer("stdout", outW), lines.writer("stderr", errW)
+			outW, errW = outWriter, errWriter
+		}
+		if st.maxOutputBytes > 0 {
+			limit = newOutputLimit(st.maxOutputBytes, cancel)
+			outW, errW = limit.writer(outW), limit.writer(errW)
+		}
+		// The same writer on both makes exec share one pipe, so writes keep their order
+		if tc.mergeStreams {
+			errW = outW
+		}
+		// These must stay writers rather than StdoutPipe/StderrPipe read after Wait: exec
+		// drains writers from its own goroutines while the shell runs, so a full stderr
+		// pipe can't block the shell while stdin is still being fed to it
+		cmd.Stdout, cmd.Stderr = outW, errW
+
+		stdin, err = cmd.StdinPipe()
+		if err != nil {
+			return commandResult{stderr: err.Error(), exitCode: 1}
+		}
+
+		err = cmd.Start()
+		if err == nil {
+			break
+		}
+		if attempt >= startRetries || !isTransientStartError(err) {
+			return commandResult{stderr: err.Error(), exitCode: 1}
+		}
+		time.Sleep(startBackoff << attempt)
+	}
+
+	// Feed stdin concurrently so a command larger than the pipe buffer can't block
+	// the tester before the shell starts reading it
+	started := time.Now()
+	writeErr := make(chan error, 1)
+	go func() {
+		_, err := stdin.Write([]byte(input))
+		_ = stdin.Close()
+		writeErr <- err
+	}()
 
 	err = cmd.Wait()
+	duration := time.Since(started)
+	peak := peakRSS(cmd.ProcessState)
+	var leftovers []string
+	if st.checkLeftovers {
+		leftovers = reapLeftovers(cmd.Process.Pid)
+	}
+	// Wait closes stdin, so a write still in progress returns here; a shell that
+	// exits before reading all of its input is not an error
+	if werr := <-writeErr; werr != nil && !errors.Is(werr, syscall.EPIPE) && !errors.Is(werr, os.ErrClosed) {
+		return commandResult{stderr: werr.Error(), exitCode: 1}
+	}
 	exitCode := 0
 	if err != nil {
 		var exitErr *exec.ExitError
@@ -93,161 +664,1054 @@ func (st *ShellTester) runCommand(shellPath, command string) (string, string, in
 		}
 	}
 
-	return strings.TrimSpace(stdout.String()), strings.TrimSpace(stderr.String()), exitCode
+	timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+	truncated := limit != nil && limit.truncated()
+	signal, crash := "", ""
+	if !timedOut && !truncated {
+		signal, crash = terminationSignal(err, exitCode), crashSignal(err)
+	}
+
+	trace := ""
+	if traceFile != "" {
+		trace = readTrace(traceFile)
+	}
+
+	combinedOut := ""
+	if combined != nil {
+		combinedOut = strings.TrimSpace(combined.String())
+	}
+
+	var timedLines []TimedLine
+	if lines != nil {
+		outWriter.flush()
+		errWriter.flush()
+		timedLines = lines.lines
+	}
+
+	return commandResult{
+		timeline:         timedLines,
+		stdout:           strings.TrimSpace(stdout.String()),
+		stderr:           strings.TrimSpace(stderr.String()),
+		exitCode:         exitCode,
+		timedOut:         timedOut,
+		finalNewline:     bytes.HasSuffix(stdout.Bytes(), []byte("\n")),
+		trailingNewlines: len(stdout.Bytes()) - len(bytes.TrimRight(stdout.Bytes(), "\n")),
+		truncated:        truncated,
+		signal:           signal,
+		crash:            crash,
+		duration:         duration,
+		peakRSS:          peak,
+		leftovers:        leftovers,
+		combined:         combinedOut,
+		strace:           trace,
+	}
+}
+
+// smokeTest checks that minishell can run a trivial command before the suite starts
+func (st *ShellTester) smokeTest() error {
+	res := st.runCommand(st.minishellPath, TestCase{Command: "echo hello"})
+	if res.timedOut || !strings.Contains(res.stdout, "hello") {
+		return fmt.Errorf("minishell doesn't appear to be a working shell: 'echo hello' printed %q (exit code %d)", res.stdout, res.exitCode)
+	}
+	return nil
+}
+
+// sanityWord is echoed through both shells by the startup sanity check
+const sanityWord = "__mini_tester_sanity__"
+
+// sanityCheck runs a trivial echo through both shells and describes what makes the
+// environment look misconfigured: bash failing at it, or the shells disagreeing
+func (st *ShellTester) sanityCheck() []string {
+	tc := TestCase{Command: "echo " + sanityWord}
+	bash := st.runCommand(st.bashPath, tc)
+	mini := st.runCommand(st.minishellPath, tc)
+
+	var problems []string
+	if bash.timedOut || bash.stdout != sanityWord || bash.stderr != "" || bash.exitCode != 0 {
+		problems = append(problems, fmt.Sprintf("bash printed %q with stderr %q and exit code %d (check -bash and -bash-rcfile)",
+			bash.stdout, bash.stderr, bash.exitCode))
+	}
+	if mini.stdout != bash.stdout || mini.stderr != bash.stderr || mini.exitCode != bash.exitCode {
+		problems = append(problems, fmt.Sprintf("minishell printed %q with stderr %q and exit code %d, unlike bash",
+			mini.stdout, mini.stderr, mini.exitCode))
+	}
+	return problems
+}
+
+// expectedOutputFor returns the output a test expects from minishell and whether it should be checked
+func (st *ShellTester) expectedOutputFor(tc TestCase) (string, bool, error) {
+	if tc.ExpectEmptyOutput {
+		return "", true, nil
+	}
+	if tc.ExpectedFromCommand != "" {
+		return st.expectedFromCommand(tc)
+	}
+	if tc.ExpectedOutputFile == "" {
+		return tc.ExpectedOutput, tc.ExpectedOutput != "", nil
+	}
+	data, err := os.ReadFile(tc.ExpectedOutputFile)
+	if err != nil {
+		return "", true, fmt.Errorf("error reading expected output file: %v", err)
+	}
+	return strings.TrimSpace(string(data)), true, nil
 }
 
-// compareOutput compares output between bash and minishell
+// setBashRCFile makes bash source path before every test
+func (st *ShellTester) setBashRCFile(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("bash rcfile: %v", err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("error resolving bash rcfile path: %v", err)
+	}
+	st.bashRCFile = abs
+	return nil
+}
+
+// setMinishell2 adds a second minishell build to run alongside the first
+func (st *ShellTester) setMinishell2(path string) error {
+	abs, err := resolveShellPath(path, "minishell2")
+	if err != nil {
+		return err
+	}
+	st.minishell2Path = abs
+	return nil
+}
+
+// interrupted reports whether the run was cancelled, e.g. by Ctrl-C
+func (st *ShellTester) interrupted() bool {
+	return st.ctx != nil && st.ctx.Err() != nil
+}
+
+// compareOutput compares output between bash and minishell in descending priority,
+// stopping early and keeping only completed tests if the run is interrupted, or
+// after the first minishell crash with bailOnCrash
 func (st *ShellTester) compareOutput(testCases []TestCase) map[string]TestResult {
 	results := make(map[string]TestResult)
 
-	for _, tc := range testCases {
-		bashOut, bashErr, bashRC := st.runCommand(st.bashPath, tc.Command)
-		miniOut, miniErr, miniRC := st.runCommand(st.minishellPath, tc.Command)
-
-		results[tc.Command] = TestResult{
-			Description:         tc.Description,
-			BashOutput:          bashOut,
-			MinishellOutput:     miniOut,
-			BashError:           bashErr,
-			MinishellError:      miniErr,
-			BashReturnCode:      bashRC,
-			MinishellReturnCode: miniRC,
-			OutputMatch:         bashOut == miniOut,
-			ErrorMatch:          bashErr == miniErr,
-			ReturnCodeMatch:     bashRC == miniRC,
-			ExpectedOutputMatch: tc.ExpectedOutput == "" || miniOut == tc.ExpectedOutput,
-			ExpectedErrorMatch:  tc.ExpectedError == "" || miniErr == tc.ExpectedError,
-			ExpectedCodeMatch:   tc.ExpectedCode == 0 || miniRC == tc.ExpectedCode,
+	ordered := append([]TestCase(nil), testCases...)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Priority > ordered[j].Priority })
+	for _, tc := range ordered {
+		if st.interrupted() {
+			break
+		}
+		result := st.runTest(tc)
+		if st.bailOnCrash && result.MinishellCrash != "" {
+			results[tc.resultKey()] = result
+			st.crashKey = tc.resultKey()
+			break
+		}
+		if !result.passed() && st.retries > 0 {
+			result = st.retryTest(tc, result)
+		}
+		if st.interrupted() {
+			// The shells were killed mid-test, so this result is meaningless
+			break
+		}
+		// Diverging builds still need their output for the build comparison diff
+		if st.dropPassingOutput && result.passed() && !result.BuildsDiverge {
+			result.dropOutputs()
 		}
+		results[tc.resultKey()] = result
 	}
 
 	return results
 }
 
+// retryTest reruns a failed test until it passes or runs out of retries. The last
+// attempt is returned with the full history; any pass among failures makes it flaky.
+func (st *ShellTester) retryTest(tc TestCase, first TestResult) TestResult {
+	attempts := []TestResult{first}
+	for i := 0; i < st.retries && !st.interrupted(); i++ {
+		attempt := st.runTest(tc)
+		attempts = append(attempts, attempt)
+		if attempt.passed() {
+			break
+		}
+	}
+
+	result := attempts[len(attempts)-1]
+	result.Attempts = attempts
+	result.Flaky = result.passed()
+	return result
+}
+
+// classifyFailures counts failing tests that were flaky versus failed every attempt
+func classifyFailures(results map[string]TestResult) (flaky, consistent int) {
+	for _, r := range results {
+		switch {
+		case r.Flaky:
+			flaky++
+		case len(r.Attempts) > 0:
+			consistent++
+		}
+	}
+	return flaky, consistent
+}
+
+// normalizeOutput applies the comparison filters that hold for a test's stdout
+func (st *ShellTester) normalizeOutput(tc TestCase, out string) string {
+	if printsEnv(tc.Command) {
+		out = filterEnvLines(out, st.envIgnore)
+	}
+	if len(tc.IgnoreLinesMatching) > 0 {
+		out = filterIgnoredLines(out, tc.IgnoreLinesMatching)
+	}
+	if tc.TransformOutput != "" {
+		out = applyTransforms(out, tc.TransformOutput)
+	}
+	return out
+}
+
+// normalizeError applies -normalize-error-prefix to a shell's stderr. Shells name
+// themselves by the path they were started with or its base name, and expected
+// errors may be written for either shell, so all of those are replaced.
+func (st *ShellTester) normalizeError(stderr string, shellPaths ...string) string {
+	if !st.normalizeErrorPrefix {
+		return stderr
+	}
+	names := []string{"bash", "minishell"}
+	for _, path := range shellPaths {
+		names = append(names, path, filepath.Base(path))
+	}
+	return replaceErrorPrefix(stderr, names...)
+}
+
+// outputVariants reruns both shells and reports whether every minishell output
+// was also produced by bash, along with the distinct outputs bash produced
+func (st *ShellTester) outputVariants(tc TestCase, bashOut, miniOut string) (bool, []string) {
+	seen := map[string]bool{bashOut: true}
+	variants := []string{bashOut}
+	miniOuts := []string{miniOut}
+	for i := 1; i < tc.NondeterministicRuns; i++ {
+		out := st.normalizeOutput(tc, st.runCommand(st.bashPath, tc).stdout)
+		if !seen[out] {
+			seen[out] = true
+			variants = append(variants, out)
+		}
+		miniOuts = append(miniOuts, st.normalizeOutput(tc, st.runCommand(st.minishellPath, tc).stdout))
+	}
+
+	for _, out := range miniOuts {
+		if !seen[out] {
+			return false, variants
+		}
+	}
+	return true, variants
+}
+
+// errorMatch compares the shells' stderr with the test's error comparator, or only by
+// whether both wrote something for expect_non_empty_error
+func (st *ShellTester) errorMatch(tc TestCase, bashErr, miniErr string) bool {
+	switch {
+	case tc.ExpectNonEmptyError:
+		return bashErr != "" && miniErr != ""
+	case st.ignoreStderrUnlessExpected && tc.ExpectedError == "":
+		return true
+	}
+	return errorComparators[tc.ErrorComparator](bashErr, miniErr)
+}
+
+// finalNewlineMatch compares how the shells' stdout ended: the number of trailing
+// newlines for tests with trailing_newline_significant, otherwise whether there
+// was one at all under -check-final-newline
+func (st *ShellTester) finalNewlineMatch(tc TestCase, bash, mini commandResult) bool {
+	if tc.TrailingNewlineSignificant {
+		return bash.trailingNewlines == mini.trailingNewlines
+	}
+	return !st.checkFinalNewline || bash.finalNewline == mini.finalNewline
+}
+
+// runTest runs a single test case through both shells and compares the results
+func (st *ShellTester) runTest(tc TestCase) TestResult {
+	// Run both shells in the same fresh directory so file-creating tests can't collide
+	if dir, err := newTestDir(); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Warning: %s: error creating temp directory: %v\n", tc.Description, err)
+	} else {
+		defer os.RemoveAll(dir)
+		tc.tmpDir = dir
+		tc.Command = expandTmpDir(tc.Command, dir)
+		tc.ExpectedPwd = expandTmpDir(tc.ExpectedPwd, dir)
+	}
+
+	bash, mini, cached := st.runPair(tc)
+	bashProbes, bashPwd := splitTrailer(tc, &bash)
+	miniProbes, miniPwd := splitTrailer(tc, &mini)
+	bashOut, bashErr, bashRC := st.normalizeOutput(tc, bash.stdout), st.normalizeError(bash.stderr, st.bashPath), bash.exitCode
+	miniOut, miniErr, miniRC := st.normalizeOutput(tc, mini.stdout), st.normalizeError(mini.stderr, st.minishellPath), mini.exitCode
+	expectedErr := st.normalizeError(tc.ExpectedError, st.bashPath, st.minishellPath)
+
+	// Compare post-processed output if the test asks for it, keeping the raw output for the diff
+	bashCmp, miniCmp := bashOut, miniOut
+	var postErr error
+	if tc.PostProcess != "" {
+		if bashCmp, postErr = st.postProcess(tc, bashOut); postErr == nil {
+			miniCmp, postErr = st.postProcess(tc, miniOut)
+		}
+		if postErr != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", tc.Description, postErr)
+		}
+	}
+
+	if tc.ExpectedTailLines > 0 {
+		bashCmp, miniCmp = tailLines(bashCmp, tc.ExpectedTailLines), tailLines(miniCmp, tc.ExpectedTailLines)
+	}
+
+	outputMatch := postErr == nil &&
+		outputComparators[tc.Comparator](tc, maskLines(bashCmp, tc.ExpectedLines), maskLines(miniCmp, tc.ExpectedLines))
+	var variants []string
+	if tc.NondeterministicRuns > 1 {
+		outputMatch, variants = st.outputVariants(tc, bashOut, miniOut)
+	}
+
+	expectedOutput, checkOutput, err := st.expectedOutputFor(tc)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", tc.Description, err)
+	}
+
+	result := TestResult{
+		Description:              tc.Description,
+		Command:                  tc.Command,
+		Index:                    tc.index,
+		Suite:                    tc.Suite,
+		Points:                   tc.Points,
+		BashOutput:               bashOut,
+		MinishellOutput:          miniOut,
+		BashError:                bashErr,
+		MinishellError:           miniErr,
+		BashReturnCode:           bashRC,
+		MinishellReturnCode:      miniRC,
+		OutputMatch:              outputMatch,
+		ErrorMatch:               st.errorMatch(tc, bashErr, miniErr),
+		ExpectNonEmptyError:      tc.ExpectNonEmptyError,
+		ReturnCodeMatch:          st.sameExitCode(bashRC, miniRC),
+		ExpectedOutputMatch:      err == nil && (!checkOutput || miniCmp == expectedOutput),
+		ExpectedErrorMatch:       (tc.ExpectedError == "" && !tc.ExpectEmptyError) || errorComparators[tc.ErrorComparator](expectedErr, miniErr),
+		ExpectedCodeMatch:        tc.ExpectedCode == 0 || miniRC == tc.ExpectedCode,
+		LineMismatches:           lineMismatches(miniCmp, tc.ExpectedLines),
+		MinishellCombined:        mini.combined,
+		ExpectedCombinedMatch:    tc.ExpectedCombined == "" || mini.combined == tc.ExpectedCombined,
+		BashPwd:                  bashPwd,
+		MinishellPwd:             miniPwd,
+		ExpectedPwd:              tc.ExpectedPwd,
+		PwdMatch:                 tc.ExpectedPwd == "" || (miniPwd == tc.ExpectedPwd && miniPwd == bashPwd),
+		InterleavingMatch:        true,
+		BashProbeOutputs:         bashProbes,
+		MinishellProbeOutputs:    miniProbes,
+		FailedProbes:             failedProbes(tc, bashProbes, miniProbes),
+		FailedAssertions:         failedAssertions(tc.Assertions, assertionRun{stdout: miniCmp, stderr: miniErr, exitCode: miniRC}),
+		MinishellDurationMs:      mini.duration.Milliseconds(),
+		MinDurationMet:           mini.duration >= time.Duration(tc.MinDurationMs)*time.Millisecond,
+		BashSignal:               bash.signal,
+		MinishellSignal:          mini.signal,
+		MinishellCrash:           minishellCrash(bash, mini),
+		ExpectedSignalMatch:      tc.ExpectedSignal == "" || mini.signal == normalizeSignalName(tc.ExpectedSignal),
+		BashTimedOut:             bash.timedOut,
+		MinishellTimedOut:        mini.timedOut,
+		BashOutputTruncated:      bash.truncated,
+		MinishellOutputTruncated: mini.truncated,
+		BashFinalNewline:         bash.finalNewline,
+		MinishellFinalNewline:    mini.finalNewline,
+		FinalNewlineMatch:        st.finalNewlineMatch(tc, bash, mini),
+		ExpectedOutput:           expectedOutput,
+		ExpectedError:            expectedErr,
+		ExpectedLines:            tc.ExpectedLines,
+		ExpectedCombined:         tc.ExpectedCombined,
+		BashTimeline:             bash.timeline,
+		MinishellTimeline:        mini.timeline,
+		TimelineDivergence:       timelineDivergence(bash.timeline, mini.timeline),
+		BashOutputVariants:       variants,
+
+		Cached:                     cached,
+		BashLeftoverProcesses:      bash.leftovers,
+		MinishellLeftoverProcesses: mini.leftovers,
+	}
+
+	result.ExpectedLinesMatch = len(result.LineMismatches) == 0
+	if !result.passed() {
+		result.MinishellStrace = mini.strace
+	}
+	if tc.PostProcess != "" {
+		result.BashPostProcessed, result.MinishellPostProcessed = bashCmp, miniCmp
+	}
+	if st.peakMemory {
+		result.BashPeakRSSKB, result.MinishellPeakRSSKB = bash.peakRSS, mini.peakRSS
+	}
+	if tc.Comparator == "env-set" && !outputMatch {
+		result.VariableDifferences = envVarDifferences(bashCmp, miniCmp, tc.IgnoreKeys)
+	}
+	if tc.CheckInterleaving {
+		merged := tc
+		merged.mergeStreams = true
+		result.BashMerged = st.runCommand(st.bashPath, merged).stdout
+		result.MinishellMerged = st.runCommand(st.minishellPath, merged).stdout
+		result.InterleavingMatch = result.BashMerged == result.MinishellMerged
+	}
+	if tc.TrailingNewlineSignificant {
+		result.BashTrailingNewlines, result.MinishellTrailingNewlines = bash.trailingNewlines, mini.trailingNewlines
+	}
+
+	if st.minishell2Path != "" {
+		mini2 := st.runCommand(st.minishell2Path, tc)
+		mini2.stderr = st.normalizeError(mini2.stderr, st.minishell2Path)
+		splitTrailer(tc, &mini2)
+		mini2Out := st.normalizeOutput(tc, mini2.stdout)
+		result.Minishell2Output = mini2Out
+		result.Minishell2Error = mini2.stderr
+		result.Minishell2ReturnCode = mini2.exitCode
+		result.Minishell2TimedOut = mini2.timedOut
+		result.Minishell2Match = outputComparators[tc.Comparator](tc, bashOut, mini2Out) &&
+			st.errorMatch(tc, bashErr, mini2.stderr) &&
+			st.sameExitCode(bashRC, mini2.exitCode) && !mini2.timedOut
+		result.BuildsDiverge = miniOut != mini2Out || miniErr != mini2.stderr || miniRC != mini2.exitCode ||
+			mini.timedOut != mini2.timedOut
+	}
+	return result
+}
+
 // generateDiff generates detailed differences for mismatched outputs
 func (st *ShellTester) generateDiff(results map[string]TestResult) map[string]string {
 	differences := make(map[string]string)
-	dmp := diffmatchpatch.New()
+	dmp := newDiffer()
 
 	for cmd, result := range results {
-		if !result.OutputMatch || !result.ErrorMatch || !result.ReturnCodeMatch {
-			diffs := dmp.DiffMain(result.BashOutput, result.MinishellOutput, false)
-			differences[cmd] = dmp.DiffPrettyText(diffs)
+		if result.BuildsDiverge {
+			differences[cmd] += fmt.Sprintf("Minishell builds diverge (return codes %d vs %d):\n%s\n",
+				result.MinishellReturnCode, result.Minishell2ReturnCode, prettyDiff(dmp, result.MinishellOutput, result.Minishell2Output))
+		}
+		if !result.passed() {
+			bashOut, miniOut := result.BashOutput, result.MinishellOutput
+			if st.ansiDiff && (hasANSI(bashOut) || hasANSI(miniOut)) {
+				bashOut, miniOut = stripANSI(bashOut), stripANSI(miniOut)
+			}
+			if len(result.VariableDifferences) > 0 {
+				differences[cmd] += "Variables differ:\n" + strings.Join(result.VariableDifferences, "\n")
+			} else {
+				differences[cmd] += prettyDiff(dmp, bashOut, miniOut)
+			}
+			if st.ansiDiff && !result.OutputMatch && (hasANSI(result.BashOutput) || hasANSI(result.MinishellOutput)) {
+				differences[cmd] += "\nANSI: " + compareANSI(result.BashOutput, result.MinishellOutput)
+			}
+			if !result.FinalNewlineMatch && result.BashFinalNewline == result.MinishellFinalNewline {
+				differences[cmd] += fmt.Sprintf("\nTrailing newlines differ: bash=%d minishell=%d",
+					result.BashTrailingNewlines, result.MinishellTrailingNewlines)
+			} else if !result.FinalNewlineMatch {
+				differences[cmd] += fmt.Sprintf("\nFinal newline differs: bash=%t minishell=%t",
+					result.BashFinalNewline, result.MinishellFinalNewline)
+			}
+			if !result.ExpectedOutputMatch {
+				differences[cmd] += "\nExpected output vs minishell:\n" + prettyDiff(dmp, result.ExpectedOutput, result.MinishellOutput)
+			}
+			if !result.ExpectedLinesMatch {
+				for _, m := range result.LineMismatches {
+					differences[cmd] += fmt.Sprintf("\nExpected line %d: %q, minishell: %q", m.Line, m.Expected, m.Got)
+				}
+			}
+			if !result.ExpectedCombinedMatch {
+				differences[cmd] += "\nExpected combined output vs minishell:\n" + prettyDiff(dmp, result.ExpectedCombined, result.MinishellCombined)
+			}
+			if !result.InterleavingMatch {
+				if sameOutputLines(result.BashMerged, result.MinishellMerged) {
+					differences[cmd] += "\nSame stdout and stderr lines, written in a different order:"
+				} else {
+					differences[cmd] += "\nMerged stdout and stderr differ:"
+				}
+				differences[cmd] += "\n" + prettyDiff(dmp, result.BashMerged, result.MinishellMerged)
+			}
+			for _, p := range result.FailedProbes {
+				differences[cmd] += "\nProbe differs: " + p
+			}
+			for _, a := range result.FailedAssertions {
+				differences[cmd] += "\nAssertion failed: " + a
+			}
+			if !result.PwdMatch {
+				differences[cmd] += fmt.Sprintf("\nWorking directory: expected %q, bash %q, minishell %q",
+					result.ExpectedPwd, result.BashPwd, result.MinishellPwd)
+			}
+			if !result.MinDurationMet {
+				differences[cmd] += fmt.Sprintf("\nMinishell finished in %dms, expected at least min_duration_ms",
+					result.MinishellDurationMs)
+			}
+			if !result.ExpectedSignalMatch {
+				differences[cmd] += fmt.Sprintf("\nExpected signal not received: bash=%q minishell=%q",
+					result.BashSignal, result.MinishellSignal)
+			}
+			if !result.ErrorMatch && result.ExpectNonEmptyError {
+				differences[cmd] += fmt.Sprintf("\nExpected both shells to write to stderr: bash wrote %d bytes, minishell %d bytes",
+					len(result.BashError), len(result.MinishellError))
+			}
+			if !result.ExpectedErrorMatch {
+				differences[cmd] += "\nExpected error vs minishell:\n" + prettyDiff(dmp, result.ExpectedError, result.MinishellError)
+			}
 		}
 	}
 
 	return differences
 }
 
-// loadTestCases loads test cases from a JSON file
-func loadTestCases(filepath string) ([]TestCase, error) {
-	data, err := os.ReadFile(filepath)
-	if err != nil {
-		return nil, fmt.Errorf("error reading file: %v", err)
+// suiteSummaries tallies pass counts per suite, in name order
+func suiteSummaries(results map[string]TestResult) []SuiteSummary {
+	bySuite := make(map[string]*SuiteSummary)
+	for _, r := range results {
+		if r.Suite == "" {
+			continue
+		}
+		sum, ok := bySuite[r.Suite]
+		if !ok {
+			sum = &SuiteSummary{Name: r.Suite}
+			bySuite[r.Suite] = sum
+		}
+		sum.TotalTests++
+		if r.passed() {
+			sum.PassedTests++
+		}
 	}
 
-	var testCases TestCases
-	if err := json.Unmarshal(data, &testCases); err != nil {
-		return nil, fmt.Errorf("error parsing JSON: %v", err)
+	summaries := make([]SuiteSummary, 0, len(bySuite))
+	for _, sum := range bySuite {
+		summaries = append(summaries, *sum)
 	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Name < summaries[j].Name })
+	return summaries
+}
 
-	return testCases.Tests, nil
+// exitCodePair counts how often a (bash, minishell) return code mismatch occurred
+type exitCodePair struct {
+	BashReturnCode      int
+	MinishellReturnCode int
+	Count               int
+}
+
+// exitCodeHistogram tabulates return code mismatches, most frequent first
+func exitCodeHistogram(results map[string]TestResult) []exitCodePair {
+	counts := make(map[[2]int]int)
+	for _, r := range results {
+		if !r.ReturnCodeMatch {
+			counts[[2]int{r.BashReturnCode, r.MinishellReturnCode}]++
+		}
+	}
+
+	pairs := make([]exitCodePair, 0, len(counts))
+	for codes, n := range counts {
+		pairs = append(pairs, exitCodePair{BashReturnCode: codes[0], MinishellReturnCode: codes[1], Count: n})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Count != pairs[j].Count {
+			return pairs[i].Count > pairs[j].Count
+		}
+		if pairs[i].BashReturnCode != pairs[j].BashReturnCode {
+			return pairs[i].BashReturnCode < pairs[j].BashReturnCode
+		}
+		return pairs[i].MinishellReturnCode < pairs[j].MinishellReturnCode
+	})
+	return pairs
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "generate":
+			runGenerate(os.Args[2:])
+			return
+		case "bench":
+			runBench(os.Args[2:])
+			return
+		case "explain":
+			runExplain(os.Args[2:])
+			return
+		case "stats":
+			runStats(os.Args[2:])
+			return
+		case "convert":
+			runConvert(os.Args[2:])
+			return
+		case "selftest":
+			runSelfTest(os.Args[2:])
+			return
+		}
+	}
+
 	bashPath := flag.String("bash", "/bin/bash", "Path to Bash executable")
 	minishellPath := flag.String("minishell", "./minishell", "Path to Minishell executable")
-	testsPath := flag.String("tests", "test_cases.json", "Path to test cases JSON file")
-	outputPath := flag.String("output", "", "Path to save test results JSON file")
+	forceLocale := flag.String("force-locale", "", "Set LC_ALL to this locale, such as C, for both shells so number and date formatting match across machines")
+	dockerBash := flag.String("docker-bash", "", "Run the reference bash inside this Docker image with 'docker run -i' instead of the local bash")
+	bashRCFile := flag.String("bash-rcfile", "", "Startup file sourced by the reference shell (bash) before each test")
+	referenceShell := flag.String("reference-shell", "", "Compare against this reference minishell instead of -bash")
+	minishell2Path := flag.String("minishell2", "", "Path to a second Minishell build to compare against the first")
+	testsPath := flag.String("tests", "test_cases.json", "Path to test cases JSON or YAML file or directory, or - for JSON on stdin")
+	command := flag.String("command", "", "Run a single command given on the command line instead of a tests file")
+	outputPath := flag.String("output", "", "Path to save test results JSON file (same as -format text,json=PATH)")
+	formatSpec := flag.String("format", "", "Comma-separated output formats, each optionally name=PATH: "+strings.Join(formatNames(), ", ")+" (default text)")
+	timeout := flag.Duration("timeout", defaultTimeout, "Default per-test timeout (overridden by a test's timeout_ms)")
+	checkFinalNewline := flag.Bool("check-final-newline", false, "Fail tests whose stdout differs in having a trailing newline")
+	normalizeExitCodes := flag.Bool("normalize-exit-codes", false, "Compare exit codes after mapping them with -exit-code-map, so equivalent failures match")
+	exitCodeMap := flag.String("exit-code-map", defaultExitCodeMap, "Comma-separated from=to exit code pairs applied to both shells with -normalize-exit-codes")
+	normalizeErrorPrefix := flag.Bool("normalize-error-prefix", false, "Replace the 'bash: ' or 'minishell: ' program name starting stderr lines with a common token before comparing")
+	ignoreStderr := flag.Bool("ignore-stderr-unless-expected", false, "Only compare stderr for tests that set expected_error")
+	webhookJSON := flag.String("webhook-json", "", "Path to write a compact Slack/Discord webhook payload summarizing the run")
+	webhookMaxFailures := flag.Int("webhook-max-failures", 10, "Maximum failing test names listed in the webhook payload")
+	exitSummary := flag.Bool("exit-summary", false, "Print a final JSON line with total/passed/failed counts")
+	knownDiffsPath := flag.String("known-diffs", "", "JSON file of command to accepted diff; failures with exactly that diff pass as accepted")
+	skipFile := flag.String("skip-file", "", "Path to a file of commands or descriptions to skip, one per line")
+	timestamps := flag.Bool("timestamps", false, "Record output line arrival times and report ordering that diverges from bash")
+	envIgnore := flag.String("env-ignore", defaultEnvIgnore, "Comma-separated variables ignored when comparing env/export output")
+	continueOnLoadError := flag.Bool("continue-on-load-error", false, "Skip test files that fail to load instead of aborting")
+	minPassRatio := flag.Float64("min-pass-ratio", 0, "Exit non-zero when the fraction of passing tests is below this value (0-1)")
+	passScore := flag.Int("pass-score", 0, "Exit non-zero when the passing tests' points add up to less than this (tests without points are worth 1)")
+	ansiDiff := flag.Bool("ansi-diff", false, "Diff visible text of colored output and report styling differences separately")
+	maxOutputBytes := flag.Int64("max-output-bytes", defaultMaxOutputBytes, "Kill a shell once its combined stdout and stderr exceed this many bytes (0 disables)")
+	noSanityCheck := flag.Bool("no-sanity-check", false, "Skip warning when bash and minishell disagree on a trivial echo before the suite")
+	noSmokeTest := flag.Bool("no-smoke-test", false, "Skip checking that minishell runs 'echo hello' before the suite")
+	noExit := flag.Bool("no-exit", false, "Don't send exit after each command unless a test sets send_exit")
+	checkLeftovers := flag.Bool("check-leftover-processes", false, "Report processes a shell leaves running in its process group after each test")
+	requireExpectations := flag.Bool("require-expectations", false, "Treat tests without any expected_* field as a configuration error")
+	retries := flag.Int("retries", 0, "Rerun a failing test up to this many times and classify it as flaky or consistently failing")
+	useCache := flag.Bool("cache", false, "Reuse results cached by earlier runs for unchanged tests and shell binaries instead of running them")
+	noCache := flag.Bool("no-cache", false, "Run every test even if -cache is set, e.g. by a config file")
+	cacheFile := flag.String("cache-file", defaultCachePath(), "Path of the result cache")
+	compact := flag.Bool("compact", false, "Print one PASS/FAIL line per test with its first difference instead of full blocks and diffs (same as -format compact)")
+	github := flag.Bool("github", false, "Also print a GitHub Actions ::error annotation for each failing test (same as adding -format github to another report)")
+	table := flag.Bool("table", false, "Print results as an aligned table with failures first instead of a block per test (same as -format table)")
+	historyDir := flag.String("history-dir", "", "Directory to append this run's summary to, for the stats subcommand")
+	straceFlag := flag.Bool("strace", false, "Run minishell under strace and keep the process-management syscall trace of failed tests (see -log-dir)")
+	logDir := flag.String("log-dir", "", "Directory to write one log file per test with full output and diff")
+	dropPassingOutput := flag.Bool("drop-passing-output", false, "Discard the captured output of passing tests to save memory; results files and logs then omit it")
+	cpuProfile := flag.String("profile", "", "Write a CPU profile of the tester itself to this file, for go tool pprof")
+	memProfile := flag.String("mem-profile", "", "Write a heap profile of the tester itself to this file once the run finishes")
+	testRange := flag.String("range", "", "Run only the tests numbered N or N-M (1-based, in load order), e.g. 100-150")
+	tagsAny := flag.String("tags-any", "", "Comma-separated tags; run only tests carrying at least one of them")
+	tagsAll := flag.String("tags-all", "", "Comma-separated tags; run only tests carrying every one of them")
+	excludeTags := flag.String("exclude-tags", "", "Comma-separated tags; never run tests carrying any of them, even if selected by -tags-any or -tags-all")
+	interactive := flag.Bool("interactive", false, "List the loaded tests and ask which of them to run")
+	selectionFile := flag.String("selection-file", "", "File of test descriptions -interactive offers as the default selection and saves the new one to")
+	peakMemory := flag.Bool("peak-memory", false, "Record each shell's peak resident set size and list the tests where minishell used the most memory")
+	minishellMode := flag.String("minishell-mode", "stdin", "How both shells receive each test: stdin, or -c to run them as 'shell -c script'")
+	countOnly := flag.Bool("count-only", false, "Print only a passed/total line such as 48/50 once all tests have run")
+	update := flag.Bool("update", false, "Rewrite expected_output, expected_error and expected_code in the -tests file(s) from bash's current output, then exit")
+	bailOnCrash := flag.Bool("bail-on-crash", false, "Stop the run at the first test where minishell itself is killed by a crash signal such as SIGSEGV")
 	flag.Parse()
 
+	// Fill in flags not given on the command line from config files
+	if err := applyConfig(flag.CommandLine); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Profile the tester itself until the run's results are out
+	stopProfiles, err := startProfiles(*cpuProfile, *memProfile)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Resolve output formats; -compact, -table and -output are shorthands kept for existing scripts
+	if *formatSpec == "" {
+		switch {
+		case *table:
+			*formatSpec = "table"
+		case *compact:
+			*formatSpec = "compact"
+		default:
+			*formatSpec = "text"
+		}
+		if *outputPath != "" {
+			*formatSpec += ",json=" + *outputPath
+		}
+	}
+	formats, err := parseFormats(*formatSpec)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Load test cases
-	testCases, err := loadTestCases(*testsPath)
+	var testCases []TestCase
+	var skippedFiles []string
+	if *command != "" {
+		testCases = []TestCase{{Command: *command, Description: *command}}
+	} else {
+		var err error
+		testCases, skippedFiles, err = loadTestCases(*testsPath, *continueOnLoadError)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error loading test cases: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Key repeated commands apart so every test gets its own result
+	duplicates, counts, err := assignIDs(testCases)
 	if err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "Error loading test cases: %v\n", err)
 		os.Exit(1)
 	}
+	for _, cmd := range duplicates {
+		_, _ = fmt.Fprintf(os.Stderr, "Warning: command %q is used by %d tests; later ones are reported as %q, ...\n",
+			cmd, counts[cmd], secondID(testCases, cmd))
+	}
+
+	// Number tests in load order, before anything is filtered out, so -range stays stable
+	for i := range testCases {
+		testCases[i].index = i + 1
+	}
+	if *testRange != "" {
+		lo, hi, err := parseRange(*testRange)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: -range: %v\n", err)
+			os.Exit(1)
+		}
+		testCases = testCases[min(lo, len(testCases)+1)-1 : min(hi, len(testCases))]
+	}
+
+	// Files outside the per-test temp directories carry over from one test to the next
+	shared := sharedOutputFiles(testCases)
+	for _, path := range sortedKeys(shared) {
+		_, _ = fmt.Fprintf(os.Stderr, "Warning: %d tests write to %s, so their results may depend on test order: %s\n",
+			len(shared[path]), path, strings.Join(shared[path], ", "))
+	}
+
+	// Refuse suites where some tests only compare against bash
+	if *requireExpectations {
+		if err := checkExpectations(testCases); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Load differences from bash that are documented as intentional
+	var knownDiffs map[string]string
+	if *knownDiffsPath != "" {
+		var err error
+		knownDiffs, err = loadKnownDiffs(*knownDiffsPath)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Keep only the tests with the requested tags
+	testCases = filterTags(testCases, parseNameList(*tagsAny), parseNameList(*tagsAll), parseNameList(*excludeTags))
+
+	// Drop tests named in the skip file
+	var skipped []SkippedTest
+	if *skipFile != "" {
+		skips, err := loadSkipList(*skipFile)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error loading skip file: %v\n", err)
+			os.Exit(1)
+		}
+		testCases, skipped = filterSkipList(testCases, skips)
+	}
+
+	// Skip tests needing utilities this machine lacks
+	var missingDeps []SkippedTest
+	testCases, missingDeps = filterMissingCommands(testCases)
+	skipped = append(skipped, missingDeps...)
+
+	// Let the user pick tests by hand, offering last time's pick as the default
+	if *interactive {
+		if *testsPath == stdioPath && *command == "" {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: -interactive reads the selection from stdin, so tests can't come from -tests -\n")
+			os.Exit(1)
+		}
+		var previous map[string]bool
+		if *selectionFile != "" {
+			if _, err := os.Stat(*selectionFile); err == nil {
+				if previous, err = loadNameList(*selectionFile, "selection file"); err != nil {
+					_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+			}
+		}
+		selected, err := selectTests(os.Stdin, os.Stdout, testCases, previous)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if *selectionFile != "" {
+			if err := saveSelection(*selectionFile, selected); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		testCases = selected
+	}
 
-	// Initialize tester
+	// Initialize tester; a reference shell takes bash's place in every comparison
+	helperBash := *bashPath
+	if *referenceShell != "" {
+		if _, err := os.Stat(*referenceShell); os.IsNotExist(err) {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: reference shell not found at %s\n", *referenceShell)
+			os.Exit(1)
+		}
+		*bashPath = *referenceShell
+		fmt.Printf("Comparing against reference shell %s (reported as bash)\n", *referenceShell)
+	}
 	tester, err := NewShellTester(*bashPath, *minishellPath)
 	if err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	if *referenceShell != "" {
+		if tester.helperBashPath, err = resolveShellPath(helperBash, "bash"); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Warning: post_process and expected_from_command need bash: %v\n", err)
+			tester.helperBashPath = helperBash
+		}
+	}
+	tester.timeout = *timeout
+	tester.checkFinalNewline = *checkFinalNewline
+	tester.ignoreStderrUnlessExpected = *ignoreStderr
+	tester.normalizeErrorPrefix = *normalizeErrorPrefix
+	tester.forceLocale = *forceLocale
+	if *normalizeExitCodes {
+		if tester.exitCodeMap, err = parseExitCodeMap(*exitCodeMap); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: -exit-code-map: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	tester.timestamps = *timestamps
+	tester.envIgnore = parseNameList(*envIgnore)
+	tester.ansiDiff = *ansiDiff
+	tester.maxOutputBytes = *maxOutputBytes
+	tester.noExit = *noExit
+	tester.checkLeftovers = *checkLeftovers
+	tester.retries = *retries
+	tester.bailOnCrash = *bailOnCrash
+	tester.peakMemory = *peakMemory
+	switch *minishellMode {
+	case "stdin":
+	case "-c":
+		tester.dashC = true
+	default:
+		_, _ = fmt.Fprintf(os.Stderr, "Error: -minishell-mode must be stdin or -c, not %q\n", *minishellMode)
+		os.Exit(1)
+	}
+	tester.dropPassingOutput = *dropPassingOutput || *countOnly
+
+	if *dockerBash != "" {
+		if err := checkDocker(); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		tester.dockerImage = *dockerBash
+	}
+	if *bashRCFile != "" {
+		if err := tester.setBashRCFile(*bashRCFile); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *minishell2Path != "" {
+		if err := tester.setMinishell2(*minishell2Path); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *straceFlag {
+		if tester.stracePath = findStrace(); tester.stracePath == "" {
+			_, _ = fmt.Fprintf(os.Stderr, "Warning: -strace ignored: strace not found in PATH\n")
+		}
+	}
+
+	// Freeze bash's current behaviour as the tests' expectations instead of running them
+	if *update {
+		if *command != "" {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: -update can't be used with -command\n")
+			os.Exit(1)
+		}
+		if err := tester.updateExpectations(*testsPath); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := stopProfiles(); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		return
+	}
+
+	if *useCache && !*noCache {
+		cache, err := openResultCache(*cacheFile, tester.bashRCFile, tester.bashPath, tester.minishellPath)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Warning: result cache disabled: %v\n", err)
+		} else {
+			tester.cache = cache
+		}
+	}
+
+	if !*noSmokeTest {
+		if err := tester.smokeTest(); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Warn loudly before a run where every test would fail for the same reason
+	if !*noSanityCheck {
+		if problems := tester.sanityCheck(); len(problems) > 0 {
+			banner := strings.Repeat("!", 60)
+			_, _ = fmt.Fprintf(os.Stderr, "%s\nWarning: sanity check 'echo %s' failed; the environment may be misconfigured:\n", banner, sanityWord)
+			for _, p := range problems {
+				_, _ = fmt.Fprintf(os.Stderr, "  %s\n", p)
+			}
+			_, _ = fmt.Fprintf(os.Stderr, "%s\n", banner)
+		}
+	}
+
+	// On Ctrl-C, kill in-flight shells and report what completed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	tester.ctx = ctx
 
 	// Run tests
+	start := time.Now()
 	results := tester.compareOutput(testCases)
-	differences := tester.generateDiff(results)
+	interrupted := tester.interrupted()
+	// A second Ctrl-C while printing results exits immediately
+	stop()
+	if tester.cache != nil {
+		if err := tester.cache.save(); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	// -count-only only needs diffs to match them against known differences
+	var differences map[string]string
+	if !*countOnly || knownDiffs != nil {
+		differences = tester.generateDiff(results)
+	}
+	var staleKnownDiffs, changedKnownDiffs []string
+	if knownDiffs != nil {
+		staleKnownDiffs, changedKnownDiffs = applyKnownDiffs(results, differences, knownDiffs)
+	}
+	elapsed := time.Since(start)
 
-	// Calculate statistics
-	totalTests := len(results)
-	passedTests := 0
-	for _, r := range results {
-		if r.OutputMatch && r.ErrorMatch && r.ReturnCodeMatch {
-			passedTests++
+	// Print nothing but the pass count
+	if *countOnly {
+		passed := 0
+		for _, r := range results {
+			if r.passed() {
+				passed++
+			}
+		}
+		fmt.Printf("%d/%d\n", passed, len(results))
+		if err := stopProfiles(); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		}
+		score, _, _ := runScore(results)
+		exitForRun(interrupted, tester.crashKey != "", passed, len(results), *minPassRatio, score, *passScore)
+		return
 	}
 
-	// Print summary
-	fmt.Printf("\nTest Summary (%d/%d passed):\n", passedTests, totalTests)
-	fmt.Println(strings.Repeat("=", 50))
+	rep := &runReport{
+		TestCases:         testCases,
+		Results:           results,
+		Differences:       differences,
+		Summary:           newSummary(results, len(skipped), elapsed),
+		Skipped:           skipped,
+		SkippedFiles:      skippedFiles,
+		StaleKnownDiffs:   staleKnownDiffs,
+		ChangedKnownDiffs: changedKnownDiffs,
+		Elapsed:           elapsed,
+		Interrupted:       interrupted,
+		Minishell2:        tester.minishell2Path != "",
+		Retries:           tester.retries > 0,
+		PeakMemory:        tester.peakMemory,
+		BashReuses:        tester.bashReuses,
+	}
+	if tester.cache != nil {
+		rep.CacheHits = tester.cache.hits
+	}
+	totalTests, passedTests := rep.Summary.TotalTests, rep.Summary.PassedTests
 
-	for cmd, result := range results {
-		status := "PASS"
-		if !result.OutputMatch || !result.ErrorMatch || !result.ReturnCodeMatch {
-			status = "FAIL"
-		}
-		fmt.Printf("\nTest: %s\n", result.Description)
-		fmt.Printf("Command: %s\n", cmd)
-		fmt.Printf("Status: %s\n", status)
-	}
-
-	// Print detailed differences
-	if len(differences) > 0 {
-		fmt.Printf("\nDetailed Differences:\n")
-		fmt.Println(strings.Repeat("=", 50))
-		for cmd, diff := range differences {
-			fmt.Printf("\nTest: %s\n", results[cmd].Description)
-			fmt.Printf("Command: %s\n", cmd)
-			fmt.Printf("\nDifferences detected:\n%s\n", diff)
-		}
-	}
-
-	// Save results if output path provided
-	if *outputPath != "" {
-		outputData := struct {
-			Summary struct {
-				TotalTests  int `json:"total_tests"`
-				PassedTests int `json:"passed_tests"`
-				FailedTests int `json:"failed_tests"`
-			} `json:"summary"`
-			Results     map[string]TestResult `json:"results"`
-			Differences map[string]string     `json:"differences"`
-		}{
-			Summary: struct {
-				TotalTests  int `json:"total_tests"`
-				PassedTests int `json:"passed_tests"`
-				FailedTests int `json:"failed_tests"`
-			}{
-				TotalTests:  totalTests,
-				PassedTests: passedTests,
-				FailedTests: totalTests - passedTests,
-			},
-			Results:     results,
-			Differences: differences,
-		}
-
-		jsonData, err := json.MarshalIndent(outputData, "", "  ")
-		if err != nil {
-			_, _ = fmt.Fprintf(os.Stderr, "Error creating JSON output: %v\n", err)
+	// Print and save the report in every requested format
+	if err := writeFormats(formats, rep); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	// Annotations go to stdout, where the Actions runner reads workflow commands, after the report
+	if *github && !strings.Contains(","+*formatSpec+",", ",github,") {
+		if err := writeGitHubReport(os.Stdout, rep); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: error writing github output: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Write per-test log files if a log directory was given
+	if *logDir != "" {
+		if err := writeTestLogs(*logDir, testCases, results, differences); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("\nPer-test logs written to %s\n", *logDir)
+	}
+
+	// Show everything about the crash that stopped the run
+	if tester.crashKey != "" {
+		crashed := results[tester.crashKey]
+		_, _ = fmt.Fprintf(os.Stderr, "\nBailed out: minishell crashed with SIG%s\n%s\n%s", crashed.MinishellCrash,
+			strings.Repeat("=", 50), formatTestLog(crashed.Command, crashed, differences[tester.crashKey]))
+	}
+
+	// Record the run for the stats subcommand; interrupted and bailed-out runs would skew the trend
+	if *historyDir != "" && !interrupted && tester.crashKey == "" {
+		if err := appendHistory(*historyDir, newHistoryEntry(results)); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+	}
 
-		if err := os.WriteFile(*outputPath, jsonData, 0644); err != nil {
-			_, _ = fmt.Fprintf(os.Stderr, "Error writing output file: %v\n", err)
+	// Write a chat-friendly summary for CI to post
+	if *webhookJSON != "" {
+		if err := writeWebhookJSON(*webhookJSON, results, *webhookMaxFailures); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+	}
 
-		fmt.Printf("\nDetailed results saved to %s\n", *outputPath)
+	// Print a single machine-readable summary line last
+	if *exitSummary {
+		line, err := json.Marshal(struct {
+			Total  int `json:"total"`
+			Passed int `json:"passed"`
+			Failed int `json:"failed"`
+		}{totalTests, passedTests, totalTests - passedTests})
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error creating exit summary: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(line))
+	}
+
+	if err := stopProfiles(); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	score, _, _ := runScore(results)
+	exitForRun(interrupted, tester.crashKey != "", passedTests, totalTests, *minPassRatio, score, *passScore)
+}
+
+// exitForRun exits non-zero if the run was interrupted, bailed out on a crash,
+// or passed too few tests or points, and returns otherwise
+func exitForRun(interrupted, crashed bool, passed, total int, minPassRatio float64, score, passScore int) {
+	// An interrupted run is never a success
+	if interrupted {
+		os.Exit(130)
+	}
+	if crashed {
+		os.Exit(1)
+	}
+
+	// Fail the run when too few tests passed
+	passRatio := 1.0
+	if total > 0 {
+		passRatio = float64(passed) / float64(total)
+	}
+	if passRatio < minPassRatio {
+		_, _ = fmt.Fprintf(os.Stderr, "Pass ratio %.2f is below -min-pass-ratio %.2f\n", passRatio, minPassRatio)
+		os.Exit(1)
+	}
+
+	// Fail the run when the passing tests score too few points
+	if score < passScore {
+		_, _ = fmt.Fprintf(os.Stderr, "Score %d is below -pass-score %d\n", score, passScore)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"syscall"
+)
+
+// topMemoryTests is how many tests the Peak Memory section lists
+const topMemoryTests = 10
+
+// peakRSS returns the peak resident set size, in KiB, of a finished shell or the
+// largest of the commands it waited for, as reported by wait4; 0 if unknown
+func peakRSS(state *os.ProcessState) int64 {
+	if state == nil {
+		return 0
+	}
+	if usage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		return usage.Maxrss
+	}
+	return 0
+}
+
+// memoryHungryTests returns the keys of up to n results with the highest minishell peak RSS
+func memoryHungryTests(results map[string]TestResult, n int) []string {
+	keys := make([]string, 0, len(results))
+	for key, r := range results {
+		if r.MinishellPeakRSSKB > 0 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := results[keys[i]].MinishellPeakRSSKB, results[keys[j]].MinishellPeakRSSKB
+		if a != b {
+			return a > b
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	return keys
+}
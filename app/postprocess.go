@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// helperCmd runs script with the configured bash, never a -reference-shell, in the test's
+// directory and with the environment the tested shells get, TEST_TMPDIR included
+func (st *ShellTester) helperCmd(ctx context.Context, tc TestCase, script string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, st.helperBashPath, "-c", script)
+	cmd.Dir = tc.tmpDir
+	cmd.Env = st.shellEnv(st.helperBashPath, tc)
+	return cmd
+}
+
+// postProcess pipes a shell's captured stdout through the test's post_process
+// command, run by bash, and returns what it printed
+func (st *ShellTester) postProcess(tc TestCase, output string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), st.timeoutFor(tc))
+	defer cancel()
+
+	cmd := st.helperCmd(ctx, tc, tc.PostProcess)
+	cmd.Stdin = strings.NewReader(output + "\n")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("post_process %q failed: %v", tc.PostProcess, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// expectedFromCommand runs the test's expected_from_command in bash and returns
+// what it printed as the expected output
+func (st *ShellTester) expectedFromCommand(tc TestCase) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), st.timeoutFor(tc))
+	defer cancel()
+
+	cmd := st.helperCmd(ctx, tc, tc.ExpectedFromCommand)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", true, fmt.Errorf("expected_from_command %q failed: %v", tc.ExpectedFromCommand, err)
+	}
+	return strings.TrimSpace(string(out)), true, nil
+}
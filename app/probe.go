@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// probeMarker is echoed on its own line before each assert_after probe to tell their outputs apart
+const probeMarker = "__mini_tester_probe__"
+
+// probeInput is the script that runs a test's assert_after probes once its command is done;
+// the bare echo keeps the marker on its own line after output lacking a final newline
+func probeInput(probes []string) string {
+	var sb strings.Builder
+	for _, probe := range probes {
+		fmt.Fprintf(&sb, "echo\necho %s\n%s\n", probeMarker, probe)
+	}
+	return sb.String()
+}
+
+// splitProbes separates the outputs of n probes from the rest of a trimmed stdout;
+// probes that never ran, because the shell exited first, have empty output
+func splitProbes(stdout string, n int) (string, []string) {
+	segments := []string{""}
+	for _, line := range strings.Split(stdout, "\n") {
+		if line == probeMarker {
+			segments = append(segments, "")
+			continue
+		}
+		segments[len(segments)-1] += line + "\n"
+	}
+	for i := range segments {
+		segments[i] = strings.TrimSpace(segments[i])
+	}
+	outputs := make([]string, n)
+	copy(outputs, segments[1:])
+	return segments[0], outputs
+}
+
+// validateProbes reports an error if assert_expected doesn't pair up with assert_after
+func validateProbes(after, expected []string) error {
+	if len(expected) > 0 && len(expected) != len(after) {
+		return fmt.Errorf("assert_expected has %d entries for %d assert_after probes", len(expected), len(after))
+	}
+	return nil
+}
+
+// failedProbes describes each probe whose minishell output differs from assert_expected,
+// or from bash's when the test gives no assert_expected
+func failedProbes(tc TestCase, bash, mini []string) []string {
+	var failed []string
+	for i, probe := range tc.AssertAfter {
+		want, from := bash[i], "bash"
+		if len(tc.AssertExpected) > 0 {
+			want, from = tc.AssertExpected[i], "expected"
+		}
+		if mini[i] != want {
+			failed = append(failed, fmt.Sprintf("%s: %s %q, minishell %q", probe, from, want, mini[i]))
+		}
+	}
+	return failed
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// startProfiles starts a CPU profile written to cpuPath, if set, and returns a function
+// that stops it and writes a heap profile to memPath, if set
+func startProfiles(cpuPath, memPath string) (func() error, error) {
+	var cpuFile *os.File
+	if cpuPath != "" {
+		var err error
+		if cpuFile, err = os.Create(cpuPath); err != nil {
+			return nil, fmt.Errorf("error creating CPU profile: %v", err)
+		}
+		if err := pprof.StartCPUProfile(cpuFile); err != nil {
+			_ = cpuFile.Close()
+			return nil, fmt.Errorf("error starting CPU profile: %v", err)
+		}
+	}
+
+	return func() error {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			if err := cpuFile.Close(); err != nil {
+				return fmt.Errorf("error writing CPU profile: %v", err)
+			}
+		}
+		if memPath == "" {
+			return nil
+		}
+		memFile, err := os.Create(memPath)
+		if err != nil {
+			return fmt.Errorf("error creating memory profile: %v", err)
+		}
+		defer memFile.Close()
+		// Collect garbage first so the profile shows live memory
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(memFile); err != nil {
+			return fmt.Errorf("error writing memory profile: %v", err)
+		}
+		return nil
+	}, nil
+}
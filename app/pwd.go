@@ -0,0 +1,16 @@
+package main
+
+import "strings"
+
+// pwdProbe is run last after the command of a test with expected_pwd; the echo keeps
+// pwd's output on its own line when an assert_after probe's output lacks a final newline
+const pwdProbe = "echo\npwd"
+
+// splitPwd separates the line pwdProbe printed from the rest of a trimmed stdout
+func splitPwd(stdout string) (string, string) {
+	i := strings.LastIndex(stdout, "\n")
+	if i < 0 {
+		return "", stdout
+	}
+	return strings.TrimSpace(stdout[:i]), stdout[i+1:]
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// redirectTarget matches the file a > or >> redirection writes to; quoting and
+// > inside strings aren't understood, so this is only a heuristic
+var redirectTarget = regexp.MustCompile(`>>?\s*([^\s;|&<>()]+)`)
+
+// outputFiles returns the absolute paths a command redirects output to. Relative
+// paths land in the test's own temp directory and can't affect other tests.
+func outputFiles(command string) []string {
+	var paths []string
+	for _, m := range redirectTarget.FindAllStringSubmatch(command, -1) {
+		path := strings.Trim(m[1], `"'`)
+		if strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "/dev/") {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// sharedOutputFiles maps each absolute path that more than one test redirects
+// output to onto the descriptions of those tests, in test order
+func sharedOutputFiles(testCases []TestCase) map[string][]string {
+	writers := make(map[string][]string)
+	for _, tc := range testCases {
+		seen := make(map[string]bool)
+		for _, path := range outputFiles(tc.Command) {
+			if !seen[path] {
+				seen[path] = true
+				writers[path] = append(writers[path], tc.Description)
+			}
+		}
+	}
+	for path, tests := range writers {
+		if len(tests) < 2 {
+			delete(writers, path)
+		}
+	}
+	return writers
+}
+
+// sortedKeys returns a map's keys in order
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
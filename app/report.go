@@ -0,0 +1,322 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runReport is everything a finished run hands to the output formats
+type runReport struct {
+	TestCases       []TestCase
+	Results         map[string]TestResult
+	Differences     map[string]string
+	Summary         Summary
+	Skipped         []SkippedTest
+	SkippedFiles    []string
+	StaleKnownDiffs []string
+	// ChangedKnownDiffs are known differences whose test still fails with another diff
+	ChangedKnownDiffs []string
+	Elapsed           time.Duration
+	Interrupted       bool
+	CacheHits         int
+	BashReuses        int
+	// Minishell2, Retries and PeakMemory say whether those optional measurements were made
+	Minishell2 bool
+	Retries    bool
+	PeakMemory bool
+}
+
+// Reporter renders the results of a finished run
+type Reporter interface {
+	Report(results map[string]TestResult, summary Summary) error
+}
+
+// with returns a copy of the run carrying the given results and summary
+func (rep *runReport) with(results map[string]TestResult, summary Summary) *runReport {
+	c := *rep
+	c.Results, c.Summary = results, summary
+	return &c
+}
+
+// textReporter writes the human-readable report in one of the text styles
+type textReporter struct {
+	w     io.Writer
+	style string
+	run   *runReport
+}
+
+// Report writes the text report
+func (r *textReporter) Report(results map[string]TestResult, summary Summary) error {
+	return writeTextReport(r.w, r.run.with(results, summary), r.style)
+}
+
+// jsonReporter writes the full results as the JSON document saved by -output
+type jsonReporter struct {
+	w   io.Writer
+	run *runReport
+}
+
+// Report writes the JSON document
+func (r *jsonReporter) Report(results map[string]TestResult, summary Summary) error {
+	return writeJSONReport(r.w, r.run.with(results, summary))
+}
+
+// newSummary computes the aggregate statistics of a run
+func newSummary(results map[string]TestResult, skipped int, elapsed time.Duration) Summary {
+	passed := 0
+	for _, r := range results {
+		if r.passed() {
+			passed++
+		}
+	}
+	flaky, consistent := classifyFailures(results)
+	score, maxScore, _ := runScore(results)
+	return Summary{
+		TotalTests:   len(results),
+		PassedTests:  passed,
+		FailedTests:  len(results) - passed,
+		SkippedTests: skipped,
+		DurationMs:   elapsed.Milliseconds(),
+		Suites:       suiteSummaries(results),
+
+		FlakyTests:               flaky,
+		ConsistentlyFailingTests: consistent,
+		Score:                    score,
+		MaxScore:                 maxScore,
+	}
+}
+
+// writeSection writes a titled section underlined like the rest of the text report
+func writeSection(sb *strings.Builder, title string) {
+	fmt.Fprintf(sb, "\n%s\n%s\n", title, strings.Repeat("=", 50))
+}
+
+// writeTestBlocks writes the verbose multi-line block for each test
+func writeTestBlocks(sb *strings.Builder, rep *runReport) {
+	for _, result := range rep.Results {
+		status := "PASS"
+		if result.Accepted {
+			status = "PASS (accepted difference)"
+		} else if !result.passed() {
+			status = "FAIL"
+		}
+		fmt.Fprintf(sb, "\nTest: %s\n", result.Description)
+		fmt.Fprintf(sb, "Number: %d\n", result.Index)
+		fmt.Fprintf(sb, "Command: %s\n", result.Command)
+		fmt.Fprintf(sb, "Status: %s\n", status)
+		if snippet := firstDifferenceSnippet(result); len(snippet) > 0 {
+			fmt.Fprintf(sb, "First difference:\n  %s\n", strings.Join(snippet, "\n  "))
+		}
+		if result.MinishellTimedOut {
+			fmt.Fprintf(sb, "Minishell timed out\n")
+		}
+		if result.MinishellOutputTruncated {
+			fmt.Fprintf(sb, "Minishell produced excessive output and was killed\n")
+		}
+		if len(result.MinishellLeftoverProcesses) > len(result.BashLeftoverProcesses) {
+			fmt.Fprintf(sb, "Note: minishell left %d process(es) running (bash left %d): %s\n",
+				len(result.MinishellLeftoverProcesses), len(result.BashLeftoverProcesses),
+				strings.Join(result.MinishellLeftoverProcesses, ", "))
+		}
+		if rep.Minishell2 {
+			status2 := "PASS"
+			if !result.Minishell2Match {
+				status2 = "FAIL"
+			}
+			fmt.Fprintf(sb, "Minishell2: %s\n", status2)
+			if result.BuildsDiverge {
+				fmt.Fprintf(sb, "Minishell builds diverge\n")
+			}
+		}
+		if result.TimelineDivergence >= 0 {
+			fmt.Fprintf(sb, "Note: %s\n", describeDivergence(result.BashTimeline, result.MinishellTimeline, result.TimelineDivergence))
+		}
+	}
+}
+
+// writeRunSections writes the sections that follow the per-test lines in every text style
+func writeRunSections(sb *strings.Builder, rep *runReport) {
+	sum := rep.Summary
+
+	// Where the two minishell builds behaved differently
+	if rep.Minishell2 {
+		var diverged []string
+		minishell2Passed := 0
+		for cmd, r := range rep.Results {
+			if r.BuildsDiverge {
+				diverged = append(diverged, cmd)
+			}
+			if r.Minishell2Match {
+				minishell2Passed++
+			}
+		}
+		sort.Strings(diverged)
+		writeSection(sb, fmt.Sprintf("Build Comparison (minishell2 matched bash in %d/%d, %d diverged from minishell):",
+			minishell2Passed, sum.TotalTests, len(diverged)))
+		for _, cmd := range diverged {
+			fmt.Fprintf(sb, "DIVERGE  %s\n", rep.Results[cmd].Description)
+		}
+	}
+
+	// Skipped tests
+	if len(rep.Skipped) > 0 {
+		writeSection(sb, fmt.Sprintf("Skipped Tests (%d):", len(rep.Skipped)))
+		for _, sk := range rep.Skipped {
+			fmt.Fprintf(sb, "SKIP  %s (%s)\n", sk.Description, sk.Reason)
+		}
+	}
+
+	// How retried failures behaved
+	if rep.Retries && sum.TotalTests > 0 {
+		writeSection(sb, "Retry Classification:")
+		fmt.Fprintf(sb, "Flaky:                %d (%.1f%% of tests)\n", sum.FlakyTests, 100*float64(sum.FlakyTests)/float64(sum.TotalTests))
+		fmt.Fprintf(sb, "Consistently failing: %d\n", sum.ConsistentlyFailingTests)
+	}
+
+	// Accepted differences that no longer occur
+	if len(rep.StaleKnownDiffs) > 0 {
+		writeSection(sb, fmt.Sprintf("Stale Known Differences (%d):", len(rep.StaleKnownDiffs)))
+		for _, cmd := range rep.StaleKnownDiffs {
+			fmt.Fprintf(sb, "STALE  %s\n", cmd)
+		}
+	}
+
+	// Accepted differences whose test still fails, but differently
+	if len(rep.ChangedKnownDiffs) > 0 {
+		writeSection(sb, fmt.Sprintf("Changed Known Differences (%d):", len(rep.ChangedKnownDiffs)))
+		for _, cmd := range rep.ChangedKnownDiffs {
+			fmt.Fprintf(sb, "CHANGED  %s\n", cmd)
+		}
+	}
+
+	// Per-suite pass counts
+	if len(sum.Suites) > 0 {
+		writeSection(sb, "Suite Summary:")
+		for _, s := range sum.Suites {
+			fmt.Fprintf(sb, "%-30s %d/%d passed\n", s.Name, s.PassedTests, s.TotalTests)
+		}
+	}
+
+	// Test files that could not be loaded
+	if len(rep.SkippedFiles) > 0 {
+		writeSection(sb, fmt.Sprintf("Skipped Test Files (%d):", len(rep.SkippedFiles)))
+		for _, f := range rep.SkippedFiles {
+			fmt.Fprintln(sb, f)
+		}
+	}
+
+	// Tests where minishell used the most memory
+	if rep.PeakMemory {
+		if keys := memoryHungryTests(rep.Results, topMemoryTests); len(keys) > 0 {
+			writeSection(sb, fmt.Sprintf("Peak Memory (top %d minishell runs):", len(keys)))
+			for _, key := range keys {
+				r := rep.Results[key]
+				fmt.Fprintf(sb, "%8d KiB (bash %d KiB)  %s\n", r.MinishellPeakRSSKB, r.BashPeakRSSKB, r.Description)
+			}
+		}
+	}
+
+	// Run duration and throughput
+	fmt.Fprintf(sb, "\nRan %d tests in %s", sum.TotalTests, rep.Elapsed.Round(time.Millisecond))
+	if rep.Elapsed > 0 {
+		fmt.Fprintf(sb, " (%.1f tests/s)", float64(sum.TotalTests)/rep.Elapsed.Seconds())
+	}
+	fmt.Fprintln(sb)
+	if _, _, weighted := runScore(rep.Results); weighted {
+		fmt.Fprintf(sb, "Score: %d/%d points (%.1f%%)\n", sum.Score, sum.MaxScore, 100*float64(sum.Score)/float64(sum.MaxScore))
+	}
+	if rep.CacheHits > 0 {
+		fmt.Fprintf(sb, "Reused %d cached result(s); run without -cache to rerun everything\n", rep.CacheHits)
+	}
+	if rep.BashReuses > 0 {
+		fmt.Fprintf(sb, "Ran bash once for %d test(s) repeating an earlier invocation\n", rep.BashReuses)
+	}
+
+	// Exit code mismatch histogram
+	if histogram := exitCodeHistogram(rep.Results); len(histogram) > 0 {
+		writeSection(sb, "Exit Code Mismatches:")
+		for _, p := range histogram {
+			fmt.Fprintf(sb, "bash=%-4d minishell=%-4d %d test(s)\n", p.BashReturnCode, p.MinishellReturnCode, p.Count)
+		}
+	}
+}
+
+// writeDetailedDiffs writes the full diff of every failing test, showing tests that
+// differ in the same way once under a count of how many did
+func writeDetailedDiffs(sb *strings.Builder, rep *runReport) {
+	if len(rep.Differences) == 0 {
+		return
+	}
+	writeSection(sb, "Detailed Differences:")
+	for _, g := range groupDifferences(rep.Results, rep.Differences) {
+		first := g.Keys[0]
+		if len(g.Keys) == 1 {
+			fmt.Fprintf(sb, "\nTest: %s\n", rep.Results[first].Description)
+			fmt.Fprintf(sb, "Command: %s\n", rep.Results[first].Command)
+			fmt.Fprintf(sb, "\nDifferences detected:\n%s\n", rep.Differences[first])
+			continue
+		}
+		fmt.Fprintf(sb, "\n%d tests: %s\n", len(g.Keys), g.Label)
+		for _, key := range g.Keys {
+			fmt.Fprintf(sb, "  %s\n", rep.Results[key].Description)
+		}
+		fmt.Fprintf(sb, "\nDifferences detected in %s:\n%s\n", rep.Results[first].Description, rep.Differences[first])
+	}
+}
+
+// writeTextReport writes the human-readable report, with tests rendered by the given style
+func writeTextReport(w io.Writer, rep *runReport, style string) error {
+	var sb strings.Builder
+	if rep.Interrupted {
+		fmt.Fprintf(&sb, "\nInterrupted: %d of %d tests completed\n", rep.Summary.TotalTests, len(rep.TestCases))
+	}
+	writeSection(&sb, fmt.Sprintf("Test Summary (%d/%d passed):", rep.Summary.PassedTests, rep.Summary.TotalTests))
+
+	switch style {
+	case "table":
+		fmt.Fprintln(&sb)
+		writeTable(&sb, rep.Results)
+	case "compact":
+		fmt.Fprintln(&sb)
+		writeCompact(&sb, rep.TestCases, rep.Results)
+	default:
+		writeTestBlocks(&sb, rep)
+	}
+	writeRunSections(&sb, rep)
+	// Compact mode already showed the first difference of each test
+	if style != "compact" {
+		writeDetailedDiffs(&sb, rep)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeJSONReport writes the full results as indented JSON, along with the test
+// cases that produced them so the file can be replayed with -tests
+func writeJSONReport(w io.Writer, rep *runReport) error {
+	outputData := struct {
+		Summary     Summary               `json:"summary"`
+		Results     map[string]TestResult `json:"results"`
+		Differences map[string]string     `json:"differences"`
+		Skipped     []SkippedTest         `json:"skipped,omitempty"`
+		TestCases   []TestCase            `json:"test_cases"`
+	}{
+		Summary:     rep.Summary,
+		Results:     rep.Results,
+		Differences: rep.Differences,
+		Skipped:     rep.Skipped,
+		TestCases:   rep.TestCases,
+	}
+
+	jsonData, err := json.MarshalIndent(outputData, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error creating JSON output: %v", err)
+	}
+	_, err = w.Write(append(jsonData, '\n'))
+	return err
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newBashTester returns a tester that runs bash as both shells, skipping the test if there is none
+func newBashTester(t *testing.T) *ShellTester {
+	t.Helper()
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found in PATH")
+	}
+	st, err := NewShellTester("bash", "bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+// runWithin runs a test in bash and fails instead of hanging if it doesn't return in time
+func runWithin(t *testing.T, st *ShellTester, tc TestCase, limit time.Duration) commandResult {
+	t.Helper()
+	tc.TimeoutMs = int(limit / time.Millisecond)
+	done := make(chan commandResult, 1)
+	go func() { done <- st.runCommand(st.bashPath, tc) }()
+	select {
+	case res := <-done:
+		if res.timedOut {
+			t.Fatalf("command timed out after %v; stdout %d bytes, stderr %d bytes", limit, len(res.stdout), len(res.stderr))
+		}
+		return res
+	case <-time.After(2 * limit):
+		t.Fatalf("runCommand didn't return within %v", 2*limit)
+	}
+	return commandResult{}
+}
+
+func TestRunCommandLargeStdin(t *testing.T) {
+	st := newBashTester(t)
+	const size = 4 << 20
+	tc := TestCase{Command: "x=" + strings.Repeat("a", size) + "\necho ${#x}"}
+
+	res := runWithin(t, st, tc, 30*time.Second)
+	if res.stdout != strconv.Itoa(size) {
+		t.Errorf("stdout = %q, want %d", res.stdout, size)
+	}
+	if res.exitCode != 0 {
+		t.Errorf("exit code = %d, want 0 (stderr %q)", res.exitCode, res.stderr)
+	}
+}
+
+func TestRunCommandLargeStderr(t *testing.T) {
+	st := newBashTester(t)
+	const size = 3 << 20
+	// Bash fills the stderr pipe long before it has read the rest of its input
+	tc := TestCase{Command: "head -c " + strconv.Itoa(size) + " /dev/zero | tr '\\0' e >&2\n" +
+		"# " + strings.Repeat("p", 2<<20) + "\necho done"}
+
+	res := runWithin(t, st, tc, 30*time.Second)
+	if len(res.stderr) != size || strings.Trim(res.stderr, "e") != "" {
+		t.Errorf("stderr is %d bytes, want %d e's", len(res.stderr), size)
+	}
+	if res.stdout != "done" {
+		t.Errorf("stdout = %q, want %q", res.stdout, "done")
+	}
+}
+
+// runTestJSON loads a single test from its JSON and runs it with bash as both shells
+func runTestJSON(t *testing.T, st *ShellTester, test string) TestResult {
+	t.Helper()
+	testCases, err := parseTestCases([]byte(`{"test_cases":[`+test+`]}`), ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st.runTest(testCases[0])
+}
+
+func TestExpectedLinesAfterPostProcess(t *testing.T) {
+	st := newBashTester(t)
+	r := runTestJSON(t, st, `{"description":"sorted","command":"printf 'b\\na\\n'","post_process":"sort","expected_lines":{"1":"b"}}`)
+	if r.ExpectedLinesMatch {
+		t.Fatalf("line 1 of the sorted output matched %q", "b")
+	}
+	want := []LineMismatch{{Line: 1, Expected: "b", Got: "a"}}
+	if !reflect.DeepEqual(r.LineMismatches, want) {
+		t.Errorf("line mismatches = %+v, want %+v", r.LineMismatches, want)
+	}
+	if _, label := diffSignature(newDiffer(), r); label != `expected line 1: "b" vs "a"` {
+		t.Errorf("diff label = %q", label)
+	}
+}
+
+func TestExpectedLinesAfterTail(t *testing.T) {
+	st := newBashTester(t)
+	r := runTestJSON(t, st, `{"description":"tail","command":"printf 'x\\ny\\n'","expected_tail_lines":1,"expected_lines":{"1":"x"}}`)
+	want := []LineMismatch{{Line: 1, Expected: "x", Got: "y"}}
+	if !reflect.DeepEqual(r.LineMismatches, want) {
+		t.Errorf("line mismatches = %+v, want %+v", r.LineMismatches, want)
+	}
+	if got := firstDifference(r); got != `expected line 1: "x" vs "y"` {
+		t.Errorf("first difference = %q", got)
+	}
+
+	r = runTestJSON(t, st, `{"description":"tail","command":"printf 'x\\ny\\n'","expected_tail_lines":1,"expected_lines":{"1":"y"}}`)
+	if !r.ExpectedLinesMatch {
+		t.Errorf("line 1 of the tail didn't match: %+v", r.LineMismatches)
+	}
+}
+
+func TestExpectedPwdKeepsExitStatus(t *testing.T) {
+	st := newBashTester(t)
+	r := runTestJSON(t, st, `{"description":"failed cd","command":"printf no-newline; cd /nonexistent","expected_code":1,"expected_pwd":"{{.TmpDir}}"}`)
+	if r.MinishellReturnCode != 1 || !r.ExpectedCodeMatch {
+		t.Errorf("exit code = %d, want the failed cd's 1", r.MinishellReturnCode)
+	}
+	if !r.PwdMatch {
+		t.Errorf("pwd = %q, want %q", r.MinishellPwd, r.ExpectedPwd)
+	}
+	if r.MinishellOutput != "no-newline" {
+		t.Errorf("stdout = %q, want %q", r.MinishellOutput, "no-newline")
+	}
+	if !r.passed() {
+		t.Errorf("bash vs bash failed: %s", firstDifference(r))
+	}
+}
+
+func TestAssertAfterKeepsExitStatus(t *testing.T) {
+	st := newBashTester(t)
+	r := runTestJSON(t, st, `{"description":"export then fail","command":"export FOO=bar; false","expected_code":1,"assert_after":["echo $FOO","printf x"],"assert_expected":["bar","x"],"expected_pwd":"{{.TmpDir}}"}`)
+	if r.MinishellReturnCode != 1 || !r.ExpectedCodeMatch {
+		t.Errorf("exit code = %d, want false's 1", r.MinishellReturnCode)
+	}
+	if want := []string{"bar", "x"}; !reflect.DeepEqual(r.MinishellProbeOutputs, want) {
+		t.Errorf("probe outputs = %q, want %q", r.MinishellProbeOutputs, want)
+	}
+	if !r.PwdMatch {
+		t.Errorf("pwd = %q, want %q", r.MinishellPwd, r.ExpectedPwd)
+	}
+	if !r.passed() {
+		t.Errorf("bash vs bash failed: %s", firstDifference(r))
+	}
+}
+
+func TestHelpersIgnoreReferenceShell(t *testing.T) {
+	st := newBashTester(t)
+	// A -reference-shell replaces bashPath, which may not be a shell that takes -c
+	st.bashPath = filepath.Join(t.TempDir(), "reference-minishell")
+	tc := TestCase{Description: "sorted", PostProcess: "sort", ExpectedFromCommand: "echo expected", tmpDir: t.TempDir()}
+
+	out, err := st.postProcess(tc, "b\na")
+	if err != nil || out != "a\nb" {
+		t.Errorf("post_process = %q, %v; want %q", out, err, "a\nb")
+	}
+	out, _, err = st.expectedFromCommand(tc)
+	if err != nil || out != "expected" {
+		t.Errorf("expected_from_command = %q, %v; want %q", out, err, "expected")
+	}
+}
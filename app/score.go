@@ -0,0 +1,24 @@
+package main
+
+// testPoints returns what a passing test scores; tests without points are worth one
+func testPoints(r TestResult) int {
+	if r.Points > 0 {
+		return r.Points
+	}
+	return 1
+}
+
+// runScore sums the points of the passing tests and of all tests; weighted reports
+// whether any test set its points, without which the score is just the pass count
+func runScore(results map[string]TestResult) (score, maxScore int, weighted bool) {
+	for _, r := range results {
+		maxScore += testPoints(r)
+		if r.passed() {
+			score += testPoints(r)
+		}
+		if r.Points > 0 {
+			weighted = true
+		}
+	}
+	return score, maxScore, weighted
+}
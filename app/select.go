@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// noSuite groups tests that aren't in a named suite in the -interactive list
+const noSuite = "(no suite)"
+
+// printChoices lists tests numbered in test order, grouped by suite, marking previously selected ones
+func printChoices(w io.Writer, testCases []TestCase, previous map[string]bool) {
+	var suites []string
+	bySuite := make(map[string][]int)
+	for i, tc := range testCases {
+		suite := tc.Suite
+		if suite == "" {
+			suite = noSuite
+		}
+		if _, ok := bySuite[suite]; !ok {
+			suites = append(suites, suite)
+		}
+		bySuite[suite] = append(bySuite[suite], i)
+	}
+
+	for _, suite := range suites {
+		fmt.Fprintf(w, "\n%s:\n", suite)
+		for _, i := range bySuite[suite] {
+			mark := " "
+			if previous[testCases[i].Description] {
+				mark = "*"
+			}
+			fmt.Fprintf(w, "%s %3d  %s\n", mark, i+1, testCases[i].Description)
+		}
+	}
+}
+
+// parseSelection turns a selection like "1-3, 7 builtins" into the chosen tests'
+// indexes; entries are test numbers, ranges, suite names or "all"
+func parseSelection(input string, testCases []TestCase) (map[int]bool, error) {
+	chosen := make(map[int]bool)
+	for _, entry := range strings.FieldsFunc(input, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if entry == "all" {
+			for i := range testCases {
+				chosen[i] = true
+			}
+			continue
+		}
+		if from, to, isRange := strings.Cut(entry, "-"); isRange {
+			lo, err1 := strconv.Atoi(from)
+			hi, err2 := strconv.Atoi(to)
+			if err1 == nil && err2 == nil && lo >= 1 && lo <= hi && hi <= len(testCases) {
+				for n := lo; n <= hi; n++ {
+					chosen[n-1] = true
+				}
+				continue
+			}
+		}
+		if n, err := strconv.Atoi(entry); err == nil {
+			if n < 1 || n > len(testCases) {
+				return nil, fmt.Errorf("no test number %d", n)
+			}
+			chosen[n-1] = true
+			continue
+		}
+		found := false
+		for i, tc := range testCases {
+			if tc.Suite == entry || (entry == noSuite && tc.Suite == "") {
+				chosen[i] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%q is not a test number, range or suite", entry)
+		}
+	}
+	return chosen, nil
+}
+
+// parseRange reads a -range value: a single 1-based test number or an inclusive range like 100-150
+func parseRange(value string) (int, int, error) {
+	from, to, isRange := strings.Cut(value, "-")
+	if !isRange {
+		to = from
+	}
+	lo, err1 := strconv.Atoi(strings.TrimSpace(from))
+	hi, err2 := strconv.Atoi(strings.TrimSpace(to))
+	if err1 != nil || err2 != nil || lo < 1 || lo > hi {
+		return 0, 0, fmt.Errorf("%q is not a test number or range like 100-150", value)
+	}
+	return lo, hi, nil
+}
+
+// selectTests asks which tests to run until it gets a valid answer. An empty
+// answer keeps the previous selection, or runs everything if there isn't one.
+func selectTests(in io.Reader, out io.Writer, testCases []TestCase, previous map[string]bool) ([]TestCase, error) {
+	printChoices(out, testCases, previous)
+	reader := bufio.NewReader(in)
+	for {
+		if len(previous) > 0 {
+			fmt.Fprintf(out, "\nTests to run (numbers, ranges like 2-5, suite names or all; Enter for the * ones): ")
+		} else {
+			fmt.Fprintf(out, "\nTests to run (numbers, ranges like 2-5, suite names or all; Enter for all): ")
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			return nil, fmt.Errorf("no selection made")
+		}
+
+		var selected []TestCase
+		line = strings.TrimSpace(line)
+		if line == "" {
+			for _, tc := range testCases {
+				if len(previous) == 0 || previous[tc.Description] {
+					selected = append(selected, tc)
+				}
+			}
+			return selected, nil
+		}
+		chosen, err := parseSelection(line, testCases)
+		if err != nil {
+			fmt.Fprintf(out, "%v\n", err)
+			continue
+		}
+		for i, tc := range testCases {
+			if chosen[i] {
+				selected = append(selected, tc)
+			}
+		}
+		return selected, nil
+	}
+}
+
+// saveSelection writes the selected tests' descriptions for the next -interactive run
+func saveSelection(path string, selected []TestCase) error {
+	var sb strings.Builder
+	sb.WriteString("# Tests selected with -interactive\n")
+	for _, tc := range selected {
+		sb.WriteString(tc.Description + "\n")
+	}
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("error writing selection file: %v", err)
+	}
+	return nil
+}
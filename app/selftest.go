@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// selfTestCase is a built-in test with the comparisons it should produce against the broken shell
+type selfTestCase struct {
+	tc                                       TestCase
+	outputMatch, errorMatch, returnCodeMatch bool
+}
+
+// selfTestCases cover stdout, stderr and exit codes; the broken shell uppercases stdout,
+// drops stderr and adds one to the exit code, so each case expects a known mix of matches
+var selfTestCases = []selfTestCase{
+	{TestCase{Command: "echo hello", Description: "stdout differs"}, false, true, false},
+	{TestCase{Command: "echo 123", Description: "stdout unaffected"}, true, true, false},
+	{TestCase{Command: "ls /nonexistent_mini_tester", Description: "stderr differs"}, true, false, false},
+	{TestCase{Command: "echo a | tr a b; exit 3", Description: "pipe and exit code"}, false, true, false},
+	{TestCase{Command: "echo err >&2; echo OUT", Description: "both streams"}, true, false, false},
+}
+
+// brokenShellScript runs the given bash, uppercasing its stdout, dropping its stderr
+// and adding one to its exit code
+const brokenShellScript = `#!%s
+out=$(%s 2>/dev/null); code=$?
+[ -n "$out" ] && printf '%%s\n' "$out" | tr a-z A-Z
+exit $((code + 1))
+`
+
+// runSelfTest checks the tester's own comparisons: bash against itself must pass every
+// case, and bash against a deliberately broken shell must fail exactly as expected
+func runSelfTest(args []string) {
+	fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+	bashPath := fs.String("bash", "/bin/bash", "Path to Bash executable")
+	timeout := fs.Duration("timeout", defaultTimeout, "Timeout for each shell")
+	_ = fs.Parse(args)
+
+	dir, err := os.MkdirTemp("", "mini_tester-selftest-")
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: error creating temp directory: %v\n", err)
+		os.Exit(1)
+	}
+	defer os.RemoveAll(dir)
+	broken := filepath.Join(dir, "broken_shell")
+	if err := os.WriteFile(broken, []byte(fmt.Sprintf(brokenShellScript, *bashPath, *bashPath)), 0755); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: error writing broken shell: %v\n", err)
+		os.Exit(1)
+	}
+
+	testCases := make([]TestCase, len(selfTestCases))
+	for i, c := range selfTestCases {
+		testCases[i] = c.tc
+	}
+
+	failures := 0
+	check := func(ok bool, format string, a ...interface{}) {
+		status := "PASS"
+		if !ok {
+			status = "FAIL"
+			failures++
+		}
+		fmt.Printf("%s  %s\n", status, fmt.Sprintf(format, a...))
+	}
+
+	// bash against itself: every test passes and there is nothing to diff
+	same, err := NewShellTester(*bashPath, *bashPath)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	same.timeout = *timeout
+	results := same.compareOutput(testCases)
+	diffs := same.generateDiff(results)
+	for _, tc := range testCases {
+		r := results[tc.resultKey()]
+		check(r.passed() && diffs[tc.resultKey()] == "", "bash vs bash: %s passes with no diff", tc.Description)
+	}
+
+	// bash against the broken shell: each comparison fails as designed and is reported
+	vsBroken, err := NewShellTester(*bashPath, broken)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	vsBroken.timeout = *timeout
+	results = vsBroken.compareOutput(testCases)
+	for _, c := range selfTestCases {
+		r := results[c.tc.resultKey()]
+		check(r.OutputMatch == c.outputMatch && r.ErrorMatch == c.errorMatch && r.ReturnCodeMatch == c.returnCodeMatch,
+			"bash vs broken shell: %s gives stdout=%t stderr=%t exit code=%t", c.tc.Description, c.outputMatch, c.errorMatch, c.returnCodeMatch)
+		check(!r.passed() && firstDifference(r) != "", "bash vs broken shell: %s fails with a reported difference", c.tc.Description)
+		check(r.MinishellReturnCode == r.BashReturnCode+1, "bash vs broken shell: %s exit code %d is bash's %d plus one",
+			c.tc.Description, r.MinishellReturnCode, r.BashReturnCode)
+	}
+
+	if failures > 0 {
+		fmt.Printf("\nSelf-test failed: %d check(s) did not hold\n", failures)
+		os.Exit(1)
+	}
+	fmt.Println("\nSelf-test passed")
+}
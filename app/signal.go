@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// signalNames maps the signals a test can expect to their name without the SIG prefix
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGHUP:  "HUP",
+	syscall.SIGINT:  "INT",
+	syscall.SIGQUIT: "QUIT",
+	syscall.SIGILL:  "ILL",
+	syscall.SIGTRAP: "TRAP",
+	syscall.SIGABRT: "ABRT",
+	syscall.SIGBUS:  "BUS",
+	syscall.SIGFPE:  "FPE",
+	syscall.SIGKILL: "KILL",
+	syscall.SIGUSR1: "USR1",
+	syscall.SIGSEGV: "SEGV",
+	syscall.SIGUSR2: "USR2",
+	syscall.SIGPIPE: "PIPE",
+	syscall.SIGALRM: "ALRM",
+	syscall.SIGTERM: "TERM",
+}
+
+// normalizeSignalName turns "SIGSEGV", "sigsegv" or "SEGV" into "SEGV"
+func normalizeSignalName(name string) string {
+	return strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(name)), "SIG")
+}
+
+// validateSignalName reports an error for an expected_signal that isn't a known signal
+func validateSignalName(name string) error {
+	if name == "" {
+		return nil
+	}
+	want := normalizeSignalName(name)
+	for _, known := range signalNames {
+		if known == want {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown signal %q", name)
+}
+
+// crashSignals are the signals that mean a program crashed rather than was stopped
+var crashSignals = map[syscall.Signal]bool{
+	syscall.SIGSEGV: true,
+	syscall.SIGBUS:  true,
+	syscall.SIGABRT: true,
+	syscall.SIGILL:  true,
+	syscall.SIGFPE:  true,
+	syscall.SIGTRAP: true,
+}
+
+// crashSignal names the crash signal that killed the shell process itself, or ""
+// if it wasn't killed by one; a command the shell ran crashing doesn't count
+func crashSignal(err error) string {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() && crashSignals[status.Signal()] {
+			return signalNames[status.Signal()]
+		}
+	}
+	return ""
+}
+
+// minishellCrash returns minishell's crash signal unless bash crashed the same way,
+// as it does when a test kills its own shell with kill -SEGV $$
+func minishellCrash(bash, mini commandResult) string {
+	if bash.crash != "" {
+		return ""
+	}
+	return mini.crash
+}
+
+// terminationSignal names the signal that ended a shell run, or "" if none did.
+// A shell killed outright is reported directly; otherwise an exit code above 128
+// is taken as the shell reporting that the command it ran was killed.
+func terminationSignal(err error, exitCode int) string {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return signalNames[status.Signal()]
+		}
+	}
+	if exitCode > 128 {
+		return signalNames[syscall.Signal(exitCode-128)]
+	}
+	return ""
+}
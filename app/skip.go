@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// SkippedTest records a test case that was not run and why
+type SkippedTest struct {
+	Command     string `json:"command"`
+	Description string `json:"description"`
+	Reason      string `json:"reason"`
+}
+
+// loadSkipList reads commands or descriptions to skip, one per line, ignoring blank lines and # comments
+func loadSkipList(path string) (map[string]bool, error) {
+	return loadNameList(path, "skip file")
+}
+
+// loadNameList reads a file of test commands or descriptions, one per line, ignoring
+// blank lines and # comments; kind names the file in errors
+func loadNameList(path, kind string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", kind, err)
+	}
+	defer file.Close()
+
+	names := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names[line] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", kind, err)
+	}
+
+	return names, nil
+}
+
+// filterSkipList splits test cases into those to run and those named in the skip list
+func filterSkipList(testCases []TestCase, skips map[string]bool) ([]TestCase, []SkippedTest) {
+	var run []TestCase
+	var skipped []SkippedTest
+	for _, tc := range testCases {
+		if skips[strings.TrimSpace(tc.Command)] || skips[strings.TrimSpace(tc.Description)] {
+			skipped = append(skipped, SkippedTest{Command: tc.Command, Description: tc.Description, Reason: "skip-file"})
+			continue
+		}
+		run = append(run, tc)
+	}
+	return run, skipped
+}
+
+// filterMissingCommands splits test cases into those whose requires_commands are all
+// found in PATH and those skipped for lacking one
+func filterMissingCommands(testCases []TestCase) ([]TestCase, []SkippedTest) {
+	found := make(map[string]bool)
+	var run []TestCase
+	var skipped []SkippedTest
+	for _, tc := range testCases {
+		var missing []string
+		for _, name := range tc.RequiresCommands {
+			if _, ok := found[name]; !ok {
+				_, err := exec.LookPath(name)
+				found[name] = err == nil
+			}
+			if !found[name] {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			skipped = append(skipped, SkippedTest{Command: tc.Command, Description: tc.Description,
+				Reason: "missing dependency: " + strings.Join(missing, ", ")})
+			continue
+		}
+		run = append(run, tc)
+	}
+	return run, skipped
+}
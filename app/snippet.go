@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// snippetContext is how many characters a snippet shows on each side of the first difference
+const snippetContext = 20
+
+// visibleRunes replaces control characters so each rune takes one column, keeping the caret aligned
+func visibleRunes(s []rune) string {
+	out := make([]rune, len(s))
+	for i, r := range s {
+		if r < ' ' || r == 0x7f {
+			r = '.'
+		}
+		out[i] = r
+	}
+	return string(out)
+}
+
+// snippetWindow returns up to snippetContext runes of line around col, and where col lands in it
+func snippetWindow(line []rune, col int) (string, int) {
+	start, end := max(col-snippetContext, 0), min(col+snippetContext, len(line))
+	text, caret := visibleRunes(line[start:end]), col-start
+	if start > 0 {
+		text, caret = "..."+text, caret+3
+	}
+	if end < len(line) {
+		text += "..."
+	}
+	return text, caret
+}
+
+// diffSnippet shows the first line where two outputs differ, a few characters of context
+// around the first mismatching column, and a caret under it; it returns nil if they're equal
+func diffSnippet(wantName, want, gotName, got string) []string {
+	if want == got {
+		return nil
+	}
+	wantLines, gotLines := strings.Split(want, "\n"), strings.Split(got, "\n")
+	n := 0
+	for n < len(wantLines) && n < len(gotLines) && wantLines[n] == gotLines[n] {
+		n++
+	}
+	var w, g []rune
+	if n < len(wantLines) {
+		w = []rune(wantLines[n])
+	}
+	if n < len(gotLines) {
+		g = []rune(gotLines[n])
+	}
+	col := 0
+	for col < len(w) && col < len(g) && w[col] == g[col] {
+		col++
+	}
+
+	// Both windows start at the same column, so one caret fits both
+	wantText, caret := snippetWindow(w, col)
+	gotText, _ := snippetWindow(g, col)
+	width := max(len(wantName), len(gotName)) + 2
+	return []string{
+		fmt.Sprintf("%-*s%s", width, wantName+":", wantText),
+		fmt.Sprintf("%-*s%s", width, gotName+":", gotText),
+		fmt.Sprintf("%s^ line %d, column %d", strings.Repeat(" ", width+caret), n+1, col+1),
+	}
+}
+
+// firstDifferenceSnippet returns the diffSnippet of the output or error that made a test fail, if any
+func firstDifferenceSnippet(r TestResult) []string {
+	switch {
+	case r.Accepted || r.Flaky || r.MinishellTimedOut || r.MinishellOutputTruncated:
+		return nil
+	case !r.OutputMatch && len(r.VariableDifferences) > 0:
+		return nil
+	case !r.OutputMatch:
+		return diffSnippet("bash", r.BashOutput, "minishell", r.MinishellOutput)
+	case !r.ErrorMatch:
+		return diffSnippet("bash", r.BashError, "minishell", r.MinishellError)
+	case !r.ReturnCodeMatch || !r.FinalNewlineMatch:
+		return nil
+	case !r.ExpectedOutputMatch:
+		return diffSnippet("expected", r.ExpectedOutput, "minishell", r.MinishellOutput)
+	case !r.ExpectedErrorMatch:
+		return diffSnippet("expected", r.ExpectedError, "minishell", r.MinishellError)
+	}
+	return nil
+}
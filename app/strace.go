@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// straceSyscalls are the process-management calls traced with -strace
+const straceSyscalls = "execve,fork,vfork,clone,clone3,pipe,pipe2,dup,dup2,dup3,wait4,waitid"
+
+// findStrace returns the path of strace, or "" if it isn't installed
+func findStrace() string {
+	path, err := exec.LookPath("strace")
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// straceFile creates an empty file for strace to write a trace into, outside
+// the test's working directory so the trace can't show up in ls output
+func straceFile() (string, error) {
+	file, err := os.CreateTemp("", "mini_tester-strace-")
+	if err != nil {
+		return "", err
+	}
+	_ = file.Close()
+	return file.Name(), nil
+}
+
+// wrapStrace makes cmd run its shell under strace, following forks and writing to traceFile
+func wrapStrace(cmd *exec.Cmd, stracePath, traceFile string) {
+	cmd.Args = append([]string{stracePath, "-f", "-e", "trace=" + straceSyscalls, "-o", traceFile}, cmd.Args...)
+	cmd.Path = stracePath
+}
+
+// readTrace returns a trace's contents
+func readTrace(traceFile string) string {
+	data, _ := os.ReadFile(traceFile)
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// writeTable writes results as an aligned table with failures grouped first
+func writeTable(out io.Writer, results map[string]TestResult) {
+	rows := make([]TestResult, 0, len(results))
+	for _, r := range results {
+		rows = append(rows, r)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].passed() != rows[j].passed() {
+			return !rows[i].passed()
+		}
+		return rows[i].Description < rows[j].Description
+	})
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "#\tDESCRIPTION\tSTATUS\tBASH RC\tMINI RC")
+	for _, r := range rows {
+		status := "PASS"
+		if !r.passed() {
+			status = "FAIL"
+		}
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\n", r.Index, r.Description, status, r.BashReturnCode, r.MinishellReturnCode)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,34 @@
+package main
+
+// hasTags reports whether a test carries any of the tags or, with all set, every one of them
+func hasTags(tc TestCase, tags map[string]bool, all bool) bool {
+	seen := make(map[string]bool)
+	for _, tag := range tc.Tags {
+		if tags[tag] {
+			seen[tag] = true
+		}
+	}
+	if all {
+		return len(seen) == len(tags)
+	}
+	return len(seen) > 0
+}
+
+// filterTags keeps the tests carrying any of anyTags and all of allTags, each ignored
+// when empty, then drops those carrying any of excludeTags whatever else they carry
+func filterTags(testCases []TestCase, anyTags, allTags, excludeTags map[string]bool) []TestCase {
+	var kept []TestCase
+	for _, tc := range testCases {
+		if len(excludeTags) > 0 && hasTags(tc, excludeTags, false) {
+			continue
+		}
+		if len(anyTags) > 0 && !hasTags(tc, anyTags, false) {
+			continue
+		}
+		if len(allTags) > 0 && !hasTags(tc, allTags, true) {
+			continue
+		}
+		kept = append(kept, tc)
+	}
+	return kept
+}
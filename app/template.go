@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// tmpDirPlaceholder stands in for {{.TmpDir}} until the test's directory exists
+const tmpDirPlaceholder = "{{.TmpDir}}"
+
+// templateAction matches the start of a template action referring to a field,
+// so commands using braces for the shell, like awk '{{print}}', are left alone
+var templateAction = regexp.MustCompile(`\{\{-?\s*\.`)
+
+// commandData is what a command template can refer to
+type commandData struct {
+	TmpDir string
+	Vars   map[string]string
+}
+
+// mergeVars returns the file's vars overridden by a test's own
+func mergeVars(global, local map[string]string) map[string]string {
+	if len(global) == 0 {
+		return local
+	}
+	vars := make(map[string]string, len(global)+len(local))
+	for name, value := range global {
+		vars[name] = value
+	}
+	for name, value := range local {
+		vars[name] = value
+	}
+	return vars
+}
+
+// expandCommand expands a command's {{.Vars.name}} placeholders, leaving
+// {{.TmpDir}} to be filled in by expandTmpDir when the test runs
+func expandCommand(command string, vars map[string]string) (string, error) {
+	if !templateAction.MatchString(command) {
+		return command, nil
+	}
+	tmpl, err := template.New("command").Option("missingkey=error").Parse(command)
+	if err != nil {
+		return "", fmt.Errorf("invalid command template: %v", err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, commandData{TmpDir: tmpDirPlaceholder, Vars: vars}); err != nil {
+		return "", fmt.Errorf("error expanding command template: %v", err)
+	}
+	return sb.String(), nil
+}
+
+// expandTmpDir replaces {{.TmpDir}} in a command with the test's directory
+func expandTmpDir(command, dir string) string {
+	return strings.ReplaceAll(command, tmpDirPlaceholder, dir)
+}
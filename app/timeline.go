@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TimedLine is a line of shell output with its arrival time relative to the shell start
+type TimedLine struct {
+	Stream   string  `json:"stream"`
+	Line     string  `json:"line"`
+	OffsetMs float64 `json:"offset_ms"`
+}
+
+// timeline records output lines from several streams in arrival order
+type timeline struct {
+	mu    sync.Mutex
+	start time.Time
+	lines []TimedLine
+}
+
+// newTimeline starts a timeline whose offsets are measured from now
+func newTimeline() *timeline {
+	return &timeline{start: time.Now()}
+}
+
+// writer returns a writer that forwards to dst and records each completed line under stream
+func (t *timeline) writer(stream string, dst io.Writer) *timelineWriter {
+	return &timelineWriter{timeline: t, stream: stream, dst: dst}
+}
+
+// record appends a line stamped with the current offset
+func (t *timeline) record(stream, line string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lines = append(t.lines, TimedLine{
+		Stream:   stream,
+		Line:     line,
+		OffsetMs: float64(time.Since(t.start).Microseconds()) / 1000,
+	})
+}
+
+// timelineWriter splits one stream into lines for a timeline
+type timelineWriter struct {
+	timeline *timeline
+	stream   string
+	dst      io.Writer
+	pending  []byte
+}
+
+// Write forwards p and records any lines it completes
+func (w *timelineWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		w.timeline.record(w.stream, string(w.pending[:i]))
+		w.pending = w.pending[i+1:]
+	}
+	return w.dst.Write(p)
+}
+
+// flush records a trailing line that had no newline
+func (w *timelineWriter) flush() {
+	if len(w.pending) > 0 {
+		w.timeline.record(w.stream, string(w.pending))
+		w.pending = nil
+	}
+}
+
+// timelineJitter is how close together two lines may arrive and still count as simultaneous,
+// since stdout and stderr are read by separate goroutines
+const timelineJitter = 10 * time.Millisecond
+
+// orderedTimeline returns a copy of lines where lines arriving within the same jitter window
+// are grouped by stream, so only orderings separated by real time are compared
+func orderedTimeline(lines []TimedLine) []TimedLine {
+	ordered := append([]TimedLine(nil), lines...)
+	window := func(l TimedLine) int64 {
+		return int64(l.OffsetMs) / timelineJitter.Milliseconds()
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		wi, wj := window(ordered[i]), window(ordered[j])
+		if wi != wj {
+			return wi < wj
+		}
+		return ordered[i].Stream < ordered[j].Stream
+	})
+	return ordered
+}
+
+// timelineDivergence returns the index of the first line that arrived in a different
+// order in minishell than in bash, or -1 when the order matches or the content differs
+func timelineDivergence(bash, mini []TimedLine) int {
+	if !sameLines(bash, mini) {
+		return -1
+	}
+	bash, mini = orderedTimeline(bash), orderedTimeline(mini)
+	for i := range bash {
+		if bash[i].Stream != mini[i].Stream || bash[i].Line != mini[i].Line {
+			return i
+		}
+	}
+	return -1
+}
+
+// sameLines reports whether two timelines hold the same lines regardless of order
+func sameLines(a, b []TimedLine) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	key := func(lines []TimedLine) []string {
+		keys := make([]string, len(lines))
+		for i, l := range lines {
+			keys[i] = l.Stream + "\x00" + l.Line
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	ka, kb := key(a), key(b)
+	for i := range ka {
+		if ka[i] != kb[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// describeDivergence explains where two timelines first disagree on arrival order
+func describeDivergence(bash, mini []TimedLine, i int) string {
+	bash, mini = orderedTimeline(bash), orderedTimeline(mini)
+	return fmt.Sprintf("output arrival order diverges at line %d: bash got %s %q at %.1fms, minishell got %s %q at %.1fms",
+		i+1, bash[i].Stream, bash[i].Line, bash[i].OffsetMs, mini[i].Stream, mini[i].Line, mini[i].OffsetMs)
+}
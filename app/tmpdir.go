@@ -0,0 +1,25 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// tmpDirEnv is the variable that tells a test's command where its temp directory is
+const tmpDirEnv = "TEST_TMPDIR"
+
+// newTestDir creates a unique directory for one test's shell runs
+func newTestDir() (string, error) {
+	return os.MkdirTemp("", "mini_tester-")
+}
+
+// clearDir removes everything inside dir so each shell run starts from an empty directory
+func clearDir(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		_ = os.RemoveAll(filepath.Join(dir, entry.Name()))
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// statusMarker is echoed with the command's exit status right after a test's command when
+// more commands follow it, so the status the test is checked against stays the command's own
+const statusMarker = "__mini_tester_status__"
+
+// trailerInput returns the commands run after a test's command to inspect the shell it leaves
+// behind: the status marker, then any assert_after probes and the pwd probe, or "" if none
+func trailerInput(tc TestCase) string {
+	if tc.ExpectedPwd == "" && len(tc.AssertAfter) == 0 {
+		return ""
+	}
+	input := "echo " + statusMarker + "$?\n" + probeInput(tc.AssertAfter)
+	if tc.ExpectedPwd != "" {
+		input += pwdProbe + "\n"
+	}
+	return input
+}
+
+// splitTrailer removes what trailerInput's commands printed from a run's stdout, restores the
+// command's exit status from the marker and returns the probes' outputs and the printed pwd.
+// A shell that exited before reaching the marker keeps its output and exit status as they are.
+func splitTrailer(tc TestCase, res *commandResult) (probes []string, pwd string) {
+	if trailerInput(tc) == "" {
+		return nil, ""
+	}
+	probes = make([]string, len(tc.AssertAfter))
+	i := strings.Index(res.stdout, statusMarker)
+	if i < 0 {
+		return probes, ""
+	}
+	status, trailer, _ := strings.Cut(res.stdout[i+len(statusMarker):], "\n")
+	if code, err := strconv.Atoi(status); err == nil {
+		res.exitCode = code
+	}
+	res.stdout = strings.TrimSpace(res.stdout[:i])
+
+	if tc.ExpectedPwd != "" {
+		trailer, pwd = splitPwd(trailer)
+	}
+	if len(tc.AssertAfter) > 0 {
+		_, probes = splitProbes(trailer, len(tc.AssertAfter))
+	}
+	return probes, pwd
+}
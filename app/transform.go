@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// outputTransforms maps the transform names a test can select to their implementation
+var outputTransforms = map[string]func(string) string{
+	"trim":  strings.TrimSpace,
+	"lower": strings.ToLower,
+	"sort-lines": func(s string) string {
+		lines := strings.Split(s, "\n")
+		sort.Strings(lines)
+		return strings.Join(lines, "\n")
+	},
+	"strip-ansi": stripANSI,
+	"collapse-spaces": func(s string) string {
+		lines := strings.Split(s, "\n")
+		for i, line := range lines {
+			lines[i] = strings.Join(strings.Fields(line), " ")
+		}
+		return strings.Join(lines, "\n")
+	},
+}
+
+// transformNames splits a comma-separated transform chain into names
+func transformNames(chain string) []string {
+	var names []string
+	for _, name := range strings.Split(chain, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// validateTransforms reports an error for a transform name that doesn't exist
+func validateTransforms(chain string) error {
+	for _, name := range transformNames(chain) {
+		if _, ok := outputTransforms[name]; !ok {
+			return fmt.Errorf("unknown transform %q", name)
+		}
+	}
+	return nil
+}
+
+// applyTransforms runs output through each transform in the chain, left to right
+func applyTransforms(output, chain string) string {
+	for _, name := range transformNames(chain) {
+		if transform, ok := outputTransforms[name]; ok {
+			output = transform(output)
+		}
+	}
+	return output
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// expectedFields are the fields -update rewrites from bash's output
+var expectedFields = []string{"expected_output", "expect_empty_output", "expected_error", "expect_empty_error", "expected_code"}
+
+// marshalIndented encodes v indented like a hand-written test file, leaving <, > and &
+// unescaped so redirections in commands stay readable in the rewritten file
+func marshalIndented(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// bashExpectations runs a test in bash alone and returns what minishell is then expected
+// to print, with the same normalization, post-processing and tail the comparison uses
+func (st *ShellTester) bashExpectations(tc TestCase) (string, string, int, error) {
+	if dir, err := newTestDir(); err == nil {
+		defer os.RemoveAll(dir)
+		tc.tmpDir = dir
+		tc.Command = expandTmpDir(tc.Command, dir)
+	}
+
+	res := st.runCommand(st.bashPath, tc)
+	splitTrailer(tc, &res)
+	if res.timedOut || res.truncated {
+		return "", "", 0, fmt.Errorf("bash didn't finish normally")
+	}
+	out := st.normalizeOutput(tc, res.stdout)
+	if tc.PostProcess != "" {
+		var err error
+		if out, err = st.postProcess(tc, out); err != nil {
+			return "", "", 0, err
+		}
+	}
+	if tc.ExpectedTailLines > 0 {
+		out = tailLines(out, tc.ExpectedTailLines)
+	}
+	return out, res.stderr, res.exitCode, nil
+}
+
+// setExpectations replaces a raw test's expectations with the given ones, keeping the place
+// of fields it already had. Empty output is frozen with expect_empty_*; exit code 0 can't be
+// expressed, so it is left unchecked.
+func setExpectations(raw *orderedMap, tc TestCase, out, errOut string, code int) error {
+	fields := &orderedMap{}
+	set := fields.set
+
+	switch {
+	case tc.ExpectedFromCommand != "":
+		// The command already computes the expectation each run
+	case tc.ExpectedOutputFile != "":
+		if err := os.WriteFile(tc.ExpectedOutputFile, []byte(out+"\n"), 0644); err != nil {
+			return fmt.Errorf("error writing expected output file: %v", err)
+		}
+	case out == "":
+		set("expect_empty_output", true)
+	default:
+		set("expected_output", out)
+	}
+	if errOut == "" {
+		set("expect_empty_error", true)
+	} else {
+		set("expected_error", errOut)
+	}
+	if code != 0 {
+		set("expected_code", code)
+	}
+
+	for _, field := range expectedFields {
+		if value, ok := fields.values[field]; ok {
+			raw.set(field, value)
+		} else {
+			raw.delete(field)
+		}
+	}
+	return nil
+}
+
+// rawTests returns the test objects in a decoded test file's list, or nil if it has none
+func rawTests(list interface{}) ([]*orderedMap, error) {
+	if list == nil {
+		return nil, nil
+	}
+	items, ok := list.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("test_cases is not a list")
+	}
+	tests := make([]*orderedMap, len(items))
+	for i, item := range items {
+		if tests[i], ok = item.(*orderedMap); !ok {
+			return nil, fmt.Errorf("test_cases entry %d is not an object", i+1)
+		}
+	}
+	return tests, nil
+}
+
+// updateTestFile rewrites one test file's expectations from bash and returns how many tests it updated
+func (st *ShellTester) updateTestFile(path string) (int, error) {
+	testCases, err := loadTestFile(path)
+	if err != nil {
+		return 0, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("error reading file: %v", err)
+	}
+
+	// Decode in file order so only the expectation fields change when written back
+	doc, err := decodeOrderedJSON(data)
+	if err != nil {
+		return 0, err
+	}
+	file, ok := doc.(*orderedMap)
+	if !ok {
+		return 0, fmt.Errorf("error parsing JSON: not an object")
+	}
+
+	// Raw tests in the order parseTestCases flattens them
+	flat, err := rawTests(file.values["test_cases"])
+	if err != nil {
+		return 0, err
+	}
+	if suites, ok := file.values["suites"].([]interface{}); ok {
+		for _, s := range suites {
+			suite, ok := s.(*orderedMap)
+			if !ok {
+				return 0, fmt.Errorf("suites entry is not an object")
+			}
+			tests, err := rawTests(suite.values["test_cases"])
+			if err != nil {
+				return 0, fmt.Errorf("suite: %v", err)
+			}
+			flat = append(flat, tests...)
+		}
+	}
+	if len(flat) != len(testCases) {
+		return 0, fmt.Errorf("found %d raw tests for %d test cases", len(flat), len(testCases))
+	}
+
+	updated := 0
+	for i, tc := range testCases {
+		if st.interrupted() {
+			break
+		}
+		out, errOut, code, err := st.bashExpectations(tc)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Warning: %s: not updated: %v\n", tc.Description, err)
+			continue
+		}
+		if err := setExpectations(flat[i], tc, out, errOut, code); err != nil {
+			return updated, fmt.Errorf("test %q: %v", tc.Description, err)
+		}
+		updated++
+	}
+
+	out, err := marshalIndented(file)
+	if err != nil {
+		return updated, fmt.Errorf("error encoding test file: %v", err)
+	}
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		return updated, fmt.Errorf("error writing test file: %v", err)
+	}
+	return updated, nil
+}
+
+// updateExpectations rewrites the expectations of every test file at path, a file or directory
+func (st *ShellTester) updateExpectations(path string) error {
+	if path == stdioPath {
+		return fmt.Errorf("-update needs a test file or directory to write back to, not stdin")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("error reading file: %v", err)
+	}
+	if !info.IsDir() && testFileFormat(path) == "yaml" {
+		return fmt.Errorf("-update only rewrites JSON test files; convert %s to JSON first", path)
+	}
+	files := []string{path}
+	if info.IsDir() {
+		// YAML files in the directory are left alone
+		if files, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+			return fmt.Errorf("error listing directory: %v", err)
+		}
+		sort.Strings(files)
+	}
+
+	for _, file := range files {
+		n, err := st.updateTestFile(file)
+		if err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		fmt.Printf("Updated expectations of %d test(s) in %s\n", n, file)
+	}
+	return nil
+}
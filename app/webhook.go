@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Colors used for the webhook attachment bar
+const (
+	webhookColorPass = "#2eb886"
+	webhookColorFail = "#d40e0d"
+)
+
+// webhookPayload is a Slack-style message; Discord accepts it on its /slack webhook endpoint
+type webhookPayload struct {
+	Text        string              `json:"text"`
+	Attachments []webhookAttachment `json:"attachments"`
+}
+
+// webhookAttachment carries the colored bar and the list of failing tests
+type webhookAttachment struct {
+	Color string `json:"color"`
+	Text  string `json:"text,omitempty"`
+}
+
+// buildWebhookPayload summarizes a run with up to maxFailures failing test names
+func buildWebhookPayload(results map[string]TestResult, maxFailures int) webhookPayload {
+	var failing []string
+	for _, r := range results {
+		if !r.passed() {
+			failing = append(failing, r.Description)
+		}
+	}
+	sort.Strings(failing)
+
+	total, passed := len(results), len(results)-len(failing)
+	ratio := 100.0
+	if total > 0 {
+		ratio = 100 * float64(passed) / float64(total)
+	}
+
+	attachment := webhookAttachment{Color: webhookColorPass}
+	if len(failing) > 0 {
+		attachment.Color = webhookColorFail
+		shown := failing
+		if maxFailures >= 0 && len(shown) > maxFailures {
+			shown = shown[:maxFailures]
+		}
+		var sb strings.Builder
+		for _, name := range shown {
+			fmt.Fprintf(&sb, "• %s\n", name)
+		}
+		if more := len(failing) - len(shown); more > 0 {
+			fmt.Fprintf(&sb, "…and %d more\n", more)
+		}
+		attachment.Text = strings.TrimSuffix(sb.String(), "\n")
+	}
+
+	return webhookPayload{
+		Text:        fmt.Sprintf("minishell tests: %d/%d passed (%.1f%%)", passed, total, ratio),
+		Attachments: []webhookAttachment{attachment},
+	}
+}
+
+// writeWebhookJSON writes the webhook payload to path
+func writeWebhookJSON(path string, results map[string]TestResult, maxFailures int) error {
+	data, err := json.Marshal(buildWebhookPayload(results, maxFailures))
+	if err != nil {
+		return fmt.Errorf("error creating webhook JSON: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing webhook JSON: %v", err)
+	}
+	return nil
+}
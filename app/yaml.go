@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Test files are converted between JSON and YAML through yaml.Node trees, so mappings
+// keep their key order and numbers keep the text they were written with.
+
+// orderedMap is a JSON object or YAML mapping that keeps its keys in file order
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// set adds or replaces a key, keeping the position of an existing one
+func (m *orderedMap) set(key string, value interface{}) {
+	if m.values == nil {
+		m.values = make(map[string]interface{})
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// delete removes a key if present
+func (m *orderedMap) delete(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// MarshalJSON writes the object with its keys in order, leaving <, > and & in
+// commands unescaped
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := enc.Encode(key); err != nil {
+			return nil, err
+		}
+		buf.Truncate(buf.Len() - 1)
+		buf.WriteByte(':')
+		if err := enc.Encode(m.values[key]); err != nil {
+			return nil, err
+		}
+		buf.Truncate(buf.Len() - 1)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// decodeOrderedJSON decodes a JSON document into orderedMaps, slices, strings,
+// json.Numbers, bools and nils
+func decodeOrderedJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing JSON: %v", err)
+	}
+	if _, err := dec.Token(); err == nil {
+		return nil, fmt.Errorf("error parsing JSON: unexpected data after the document")
+	}
+	return v, nil
+}
+
+// decodeJSONValue decodes the next value from dec
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		m := &orderedMap{}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			m.set(keyTok.(string), value)
+		}
+		_, err := dec.Token()
+		return m, err
+	case json.Delim('['):
+		items := []interface{}{}
+		for dec.More() {
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		_, err := dec.Token()
+		return items, err
+	}
+	return tok, nil
+}
+
+// yamlNode builds the YAML node for a value returned by decodeOrderedJSON
+func yamlNode(v interface{}) *yaml.Node {
+	switch v := v.(type) {
+	case *orderedMap:
+		n := &yaml.Node{Kind: yaml.MappingNode}
+		for _, key := range v.keys {
+			n.Content = append(n.Content, yamlNode(key), yamlNode(v.values[key]))
+		}
+		return n
+	case []interface{}:
+		n := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, item := range v {
+			n.Content = append(n.Content, yamlNode(item))
+		}
+		return n
+	case string:
+		n := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+		// Multi-line strings read best as literal blocks, but yaml.v3 loses the leading
+		// newline of one, so those are quoted
+		switch multiLine := strings.Contains(strings.TrimRight(v, "\n"), "\n"); {
+		case multiLine && strings.HasPrefix(v, "\n"):
+			n.Style = yaml.DoubleQuotedStyle
+		case multiLine:
+			n.Style = yaml.LiteralStyle
+		}
+		return n
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(string(v), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: string(v)}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(v)}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+}
+
+// marshalYAML writes a decoded JSON object as block-style YAML
+func marshalYAML(m *orderedMap) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(yamlNode(m)); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// parseYAML parses a YAML document into the same values decodeOrderedJSON returns
+func parseYAML(data []byte) (interface{}, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("empty document")
+	}
+	return jsonValue(doc.Content[0])
+}
+
+// jsonValue converts a YAML node to orderedMaps, slices, strings, json.Numbers, bools and nils
+func jsonValue(n *yaml.Node) (interface{}, error) {
+	switch n.Kind {
+	case yaml.AliasNode:
+		return jsonValue(n.Alias)
+	case yaml.MappingNode:
+		m := &orderedMap{}
+		var merged []*orderedMap
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Tag == "!!merge" {
+				value, err := jsonValue(n.Content[i+1])
+				if err != nil {
+					return nil, err
+				}
+				// A merge key takes one mapping or a list of them
+				items, ok := value.([]interface{})
+				if !ok {
+					items = []interface{}{value}
+				}
+				for _, item := range items {
+					if mm, ok := item.(*orderedMap); ok {
+						merged = append(merged, mm)
+					}
+				}
+				continue
+			}
+			var key string
+			if err := n.Content[i].Decode(&key); err != nil {
+				return nil, fmt.Errorf("line %d: %v", n.Content[i].Line, err)
+			}
+			value, err := jsonValue(n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			m.set(key, value)
+		}
+		// Keys given in the mapping itself win over merged ones
+		for _, mm := range merged {
+			for _, key := range mm.keys {
+				if _, ok := m.values[key]; !ok {
+					m.set(key, mm.values[key])
+				}
+			}
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		items := []interface{}{}
+		for _, item := range n.Content {
+			value, err := jsonValue(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		return items, nil
+	}
+
+	// Test files have no use for dates, so they stay the text they were written as
+	if n.ShortTag() == "!!timestamp" {
+		return n.Value, nil
+	}
+	var v interface{}
+	if err := n.Decode(&v); err != nil {
+		return nil, fmt.Errorf("line %d: %v", n.Line, err)
+	}
+	switch v.(type) {
+	case int, int64, uint64, float64:
+		// Keep the number as written when JSON can take it that way
+		if json.Valid([]byte(n.Value)) {
+			return json.Number(n.Value), nil
+		}
+		out, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n.Line, err)
+		}
+		return json.Number(out), nil
+	}
+	return v, nil
+}
+
+// yamlToJSON converts a YAML test file to the JSON parseTestCases reads
+func yamlToJSON(data []byte) ([]byte, error) {
+	doc, err := parseYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing YAML: %v", err)
+	}
+	return json.Marshal(doc)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConvertRoundTrip(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no test files in testdata: %v", err)
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			yaml, err := convertTestFile(data, "json", "testdata")
+			if err != nil {
+				t.Fatalf("JSON to YAML: %v", err)
+			}
+			back, err := convertTestFile(yaml, "yaml", "testdata")
+			if err != nil {
+				t.Fatalf("YAML to JSON: %v\n%s", err, yaml)
+			}
+			if string(back) != string(data) {
+				t.Errorf("round trip changed the file\nYAML:\n%s\ngot:\n%s", yaml, back)
+			}
+		})
+	}
+}
+
+// roundTripString writes s as a YAML value and parses it back
+func roundTripString(t *testing.T, s string) (string, string) {
+	t.Helper()
+	m := &orderedMap{}
+	m.set("value", s)
+	out, err := marshalYAML(m)
+	if err != nil {
+		t.Fatalf("writing %q: %v", s, err)
+	}
+	yaml := string(out)
+	doc, err := parseYAML([]byte(yaml))
+	if err != nil {
+		t.Fatalf("parsing %q: %v", yaml, err)
+	}
+	back, ok := doc.(*orderedMap)
+	if !ok {
+		t.Fatalf("parsing %q gave %T, want a mapping", yaml, doc)
+	}
+	got, _ := back.values["value"].(string)
+	return yaml, got
+}
+
+func TestYAMLLiteralBlocks(t *testing.T) {
+	tests := []struct {
+		value  string
+		header string
+	}{
+		{"a\nb", "value: |-\n"},
+		{"a\nb\n", "value: |\n"},
+		{"a\nb\n\n", "value: |+\n"},
+		{"a\n\nb", "value: |-\n"},
+		{"echo 'x: y' # c\n  indented\n", "value: |\n"},
+		{" leading\nspace", "value: |2-\n"},
+	}
+	for _, tt := range tests {
+		yaml, got := roundTripString(t, tt.value)
+		if !strings.HasPrefix(yaml, tt.header) {
+			t.Errorf("%q written as %q, want a %q block", tt.value, yaml, strings.TrimSpace(tt.header))
+		}
+		if got != tt.value {
+			t.Errorf("%q read back as %q from %q", tt.value, got, yaml)
+		}
+	}
+}
+
+func TestYAMLQuoting(t *testing.T) {
+	tests := []struct {
+		value  string
+		quoted bool
+	}{
+		{"", true},
+		{"#", true},
+		{"# comment", true},
+		{"a # b", true},
+		{"a#b", false},
+		{":", true},
+		{"a: b", true},
+		{"a:", true},
+		{"a:b", false},
+		{"http://x", false},
+		{" leading", true},
+		{"  two leading", true},
+		{"trailing ", true},
+		{"\n leading newline", true},
+		{"\nleading newline", true},
+		{"true", true},
+		{"No", false},
+		{"null", true},
+		{"42", true},
+		{"-1", true},
+		{"- item", true},
+		{"'single'", true},
+		{"\"double\"", true},
+		{"tab\there", true},
+		{"echo hi", false},
+	}
+	for _, tt := range tests {
+		yaml, got := roundTripString(t, tt.value)
+		if quoted := strings.HasPrefix(yaml, `value: "`) || strings.HasPrefix(yaml, `value: '`); quoted != tt.quoted {
+			t.Errorf("%q written as %q, quoted = %v, want %v", tt.value, yaml, quoted, tt.quoted)
+		}
+		if got != tt.value {
+			t.Errorf("%q read back as %q from %q", tt.value, got, yaml)
+		}
+	}
+}
+
+func TestParseYAMLScalars(t *testing.T) {
+	doc, err := parseYAML([]byte("a: x # comment\nb: 'it''s: #'\nc: \"\"\nd: a#b\ne: 2024-01-01\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := doc.(*orderedMap)
+	want := map[string]string{"a": "x", "b": "it's: #", "c": "", "d": "a#b", "e": "2024-01-01"}
+	for key, value := range want {
+		if got := m.values[key]; got != value {
+			t.Errorf("%s = %#v, want %q", key, got, value)
+		}
+	}
+}
+
+func TestLoadYAMLTestFile(t *testing.T) {
+	file := filepath.Join("testdata", "tests.json")
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yaml, err := convertTestFile(data, "json", "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "tests.yml"), yaml, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := loadTestFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := loadTestCases(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("loaded %d tests from YAML, %d from JSON", len(got), len(want))
+	}
+	for i := range got {
+		got[i].sourceFile, want[i].sourceFile = "", ""
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("test %d from YAML = %+v, want %+v", i+1, got[i], want[i])
+		}
+	}
+}
+
+func TestParseYAMLMergeKeys(t *testing.T) {
+	doc, err := parseYAML([]byte("base: &b\n  a: 1\n  b: x\ntest:\n  <<: *b\n  b: y\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := doc.(*orderedMap).values["test"].(*orderedMap)
+	if got, want := test.keys, []string{"b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys = %q, want %q", got, want)
+	}
+	if test.values["b"] != "y" {
+		t.Errorf("b = %#v, want the mapping's own %q", test.values["b"], "y")
+	}
+}
@@ -2,9 +2,13 @@ module github.com/0bvim/mini_tester
 
 go 1.23.1
 
+require (
+	github.com/sergi/go-diff v1.3.1
+	github.com/spf13/cobra v1.8.1
+	gopkg.in/yaml.v3 v3.0.1
+)
+
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/sergi/go-diff v1.3.1 // indirect
-	github.com/spf13/cobra v1.8.1 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 )